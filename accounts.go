@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Directions of a transfer between a Dwolla balance and a linked bank account
+const (
+	balanceDirectionDeposit  = "deposit"  // bank -> balance
+	balanceDirectionWithdraw = "withdraw" // balance -> bank
+)
+
+// masterAccount is the platform's own Dwolla account
+type masterAccount struct {
+	ID   string `json:"id"`
+	Href string `json:"href"`
+	Name string `json:"name"`
+
+	rootLinks map[string]interface{} // _links of the root resource
+}
+
+// balanceTransferRequest moves funds between a Dwolla balance and one of its owner's bank accounts
+type balanceTransferRequest struct {
+	Direction     string            `json:"direction" binding:"required"` // "deposit" or "withdraw"
+	Amount        float64           `json:"amount" binding:"required"`
+	BankAccount   string            `json:"bank_funding_source"` // funding source ID or URL, default the first verified bank
+	CorrelationID string            `json:"correlationId"`
+	Metadata      map[string]string `json:"metadata"`
+	transferOptions
+}
+
+// fetchMasterAccount follows the root resource to the master account
+func fetchMasterAccount() (masterAccount, int, error) {
+	result, status, err := makeDwollaRequest("GET", DWOLLA_BASE_URL, nil)
+	if err != nil {
+		return masterAccount{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return masterAccount{}, status, fmt.Errorf("failed to get root resource: %v", result)
+	}
+
+	links, _ := result["_links"].(map[string]interface{})
+	accountHref := linkHref(links, "account")
+	if accountHref == "" {
+		return masterAccount{}, http.StatusInternalServerError, fmt.Errorf("account link not found")
+	}
+
+	result, status, err = makeDwollaRequest("GET", accountHref, nil)
+	if err != nil {
+		return masterAccount{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return masterAccount{}, status, fmt.Errorf("failed to get account: %v", result)
+	}
+
+	var account masterAccount
+	if err := decodeDwollaResource(result, &account); err != nil {
+		return masterAccount{}, http.StatusInternalServerError, err
+	}
+	account.Href = accountHref
+	account.rootLinks = links
+	return account, http.StatusOK, nil
+}
+
+// fetchOwnerFundingSources lists the funding sources of an account or customer that have not been removed
+func fetchOwnerFundingSources(ownerURL string) ([]fundingSource, int, error) {
+	result, status, err := makeDwollaRequest("GET", ownerURL+"/funding-sources?removed=false", nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return nil, status, fmt.Errorf("failed to get funding sources: %v", result)
+	}
+	return embeddedFundingSources(result), http.StatusOK, nil
+}
+
+// balanceFundingSource finds the balance funding source in a list
+func balanceFundingSource(fundingSources []fundingSource) (fundingSource, bool) {
+	for _, fs := range fundingSources {
+		if fs.Type == "balance" && !fs.Removed {
+			return fs, true
+		}
+	}
+	return fundingSource{}, false
+}
+
+// bankFundingSource picks a verified bank funding source from a list, either the one
+// given by ID or URL or, when ref is empty, the first one
+func bankFundingSource(fundingSources []fundingSource, ref string) (fundingSource, error) {
+	wantID := ""
+	if ref != "" {
+		id, _, err := resolveResource(resourceFundingSources, ref)
+		if err != nil {
+			return fundingSource{}, err
+		}
+		wantID = id
+	}
+
+	for _, fs := range fundingSources {
+		if fs.Type != "bank" || fs.Removed {
+			continue
+		}
+		if wantID != "" && fs.ID != wantID {
+			continue
+		}
+		if fs.Status != "verified" {
+			if wantID != "" {
+				return fundingSource{}, fmt.Errorf("bank funding source %s is %s", fs.ID, fs.Status)
+			}
+			continue
+		}
+		return fs, nil
+	}
+
+	if wantID != "" {
+		return fundingSource{}, fmt.Errorf("bank funding source %s does not belong to this owner", wantID)
+	}
+	return fundingSource{}, fmt.Errorf("no verified bank funding source")
+}
+
+// balanceTransfer moves funds between the balance and a bank account of the same owner.
+// initiateTransfer refuses withdrawals larger than the available balance.
+func balanceTransfer(fundingSources []fundingSource, req balanceTransferRequest, idempotencyKey string) (transferRecord, int, error) {
+	if req.Amount <= 0 {
+		return transferRecord{}, http.StatusBadRequest, fmt.Errorf("amount must be positive")
+	}
+	if req.Direction != balanceDirectionDeposit && req.Direction != balanceDirectionWithdraw {
+		return transferRecord{}, http.StatusBadRequest, fmt.Errorf("direction must be '%s' or '%s'", balanceDirectionDeposit, balanceDirectionWithdraw)
+	}
+
+	balance, ok := balanceFundingSource(fundingSources)
+	if !ok {
+		return transferRecord{}, http.StatusConflict, fmt.Errorf("no balance funding source")
+	}
+	bank, err := bankFundingSource(fundingSources, req.BankAccount)
+	if err != nil {
+		return transferRecord{}, http.StatusBadRequest, err
+	}
+
+	transfer := transferRequest{
+		Source:          bank.Href,
+		Destination:     balance.Href,
+		Amount:          req.Amount,
+		CorrelationID:   req.CorrelationID,
+		Metadata:        req.Metadata,
+		transferOptions: req.transferOptions,
+	}
+	if req.Direction == balanceDirectionWithdraw {
+		transfer.Source, transfer.Destination = balance.Href, bank.Href
+	}
+	return initiateTransfer(transfer, idempotencyKey)
+}
+
+// getAccounts gets the Dwolla master account with its funding sources and balance
+// GET /api/dwolla/accounts
+func getAccounts(c *gin.Context) {
+	account, status, err := fetchMasterAccount()
+	if err != nil {
+		c.JSON(status, gin.H{"error": "Failed to get accounts", "details": err.Error()})
+		return
+	}
+
+	fundingSources, status, err := fetchOwnerFundingSources(account.Href)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"account_url":     account.Href,
+		"account_id":      account.ID,
+		"name":            account.Name,
+		"funding_sources": fundingSources,
+		"_links":          account.rootLinks,
+	}
+
+	if balanceSource, ok := balanceFundingSource(fundingSources); ok {
+		balance, _, err := fetchFundingSourceBalance(balanceSource)
+		if err != nil {
+			response["balance_error"] = err.Error()
+		} else {
+			response["balance_funding_source"] = balanceSource.Href
+			response["balance"] = balance
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// createAccountTransfer moves funds between the master account balance and one of its bank accounts.
+// An optional Idempotency-Key header is passed through to Dwolla.
+// POST /api/dwolla/accounts/transfer
+func createAccountTransfer(c *gin.Context) {
+	var reqBody balanceTransferRequest
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, status, err := fetchMasterAccount()
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	fundingSources, status, err := fetchOwnerFundingSources(account.Href)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, status, err := balanceTransfer(fundingSources, reqBody, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🏦 Master account %s of %s %s: %s\n", reqBody.Direction, record.Amount, record.Currency, record.Href)
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer_id":  record.ID,
+		"transfer_url": record.Href,
+		"direction":    reqBody.Direction,
+		"source":       record.SourceURL,
+		"destination":  record.DestinationURL,
+		"amount":       record.Amount,
+		"status":       "created",
+	})
+}
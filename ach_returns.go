@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// achReturnCode describes a NACHA return reason
+type achReturnCode struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	// Retryable returns may be reinitiated (NACHA allows up to two retries for R01 and R09)
+	Retryable bool `json:"retryable"`
+	// DisableFundingSource marks returns that mean the account cannot be debited or credited again
+	DisableFundingSource bool `json:"disable_funding_source"`
+}
+
+// achReturnCodes is the catalog of ACH return codes R01-R85
+var achReturnCodes = map[string]achReturnCode{}
+
+func init() {
+	for _, rc := range []achReturnCode{
+		{"R01", "Insufficient funds", true, false},
+		{"R02", "Account closed", false, true},
+		{"R03", "No account / unable to locate account", false, true},
+		{"R04", "Invalid account number structure", false, true},
+		{"R05", "Unauthorized debit to consumer account using corporate SEC code", false, true},
+		{"R06", "Returned per ODFI's request", false, false},
+		{"R07", "Authorization revoked by customer", false, true},
+		{"R08", "Payment stopped", false, false},
+		{"R09", "Uncollected funds", true, false},
+		{"R10", "Customer advises originator is not known or not authorized to debit the account", false, true},
+		{"R11", "Customer advises entry not in accordance with the terms of the authorization", false, false},
+		{"R12", "Account sold to another DFI", false, true},
+		{"R13", "Invalid ACH routing number", false, true},
+		{"R14", "Representative payee deceased or unable to continue in that capacity", false, true},
+		{"R15", "Beneficiary or account holder deceased", false, true},
+		{"R16", "Account frozen / entry returned per OFAC instruction", false, true},
+		{"R17", "File record edit criteria / entry initiated under questionable circumstances", false, false},
+		{"R18", "Improper effective entry date", false, false},
+		{"R19", "Amount field error", false, false},
+		{"R20", "Non-transaction account", false, true},
+		{"R21", "Invalid company identification", false, false},
+		{"R22", "Invalid individual ID number", false, false},
+		{"R23", "Credit entry refused by receiver", false, false},
+		{"R24", "Duplicate entry", false, false},
+		{"R25", "Addenda error", false, false},
+		{"R26", "Mandatory field error", false, false},
+		{"R27", "Trace number error", false, false},
+		{"R28", "Routing number check digit error", false, true},
+		{"R29", "Corporate customer advises not authorized", false, true},
+		{"R30", "RDFI not participant in check truncation program", false, false},
+		{"R31", "Permissible return entry (CCD and CTX only)", false, false},
+		{"R32", "RDFI non-settlement", false, false},
+		{"R33", "Return of XCK entry", false, false},
+		{"R34", "Limited participation DFI", false, true},
+		{"R35", "Return of improper debit entry", false, false},
+		{"R36", "Return of improper credit entry", false, false},
+		{"R37", "Source document presented for payment", false, false},
+		{"R38", "Stop payment on source document", false, false},
+		{"R39", "Improper source document / source document presented for payment", false, false},
+		{"R40", "Return of ENR entry by federal government agency", false, false},
+		{"R41", "Invalid transaction code (ENR)", false, false},
+		{"R42", "Routing number / check digit error (ENR)", false, false},
+		{"R43", "Invalid DFI account number (ENR)", false, false},
+		{"R44", "Invalid individual ID number / identification number (ENR)", false, false},
+		{"R45", "Invalid individual name / company name (ENR)", false, false},
+		{"R46", "Invalid representative payee indicator (ENR)", false, false},
+		{"R47", "Duplicate enrollment (ENR)", false, false},
+		{"R50", "State law affecting RCK acceptance", false, false},
+		{"R51", "Item related to RCK entry is ineligible or RCK entry is improper", false, false},
+		{"R52", "Stop payment on item related to RCK entry", false, false},
+		{"R53", "Item and RCK entry presented for payment", false, false},
+		{"R61", "Misrouted return", false, false},
+		{"R62", "Return of erroneous or reversing debit", false, false},
+		{"R67", "Duplicate return", false, false},
+		{"R68", "Untimely return", false, false},
+		{"R69", "Field error(s)", false, false},
+		{"R70", "Permissible return entry not accepted / return not requested by ODFI", false, false},
+		{"R71", "Misrouted dishonored return", false, false},
+		{"R72", "Untimely dishonored return", false, false},
+		{"R73", "Timely original return", false, false},
+		{"R74", "Corrected return", false, false},
+		{"R75", "Return not a duplicate", false, false},
+		{"R76", "No errors found", false, false},
+		{"R77", "Non-acceptance of R62 dishonored return", false, false},
+		{"R80", "IAT entry coding error", false, false},
+		{"R81", "Non-participant in IAT program", false, false},
+		{"R82", "Invalid foreign receiving DFI identification", false, false},
+		{"R83", "Foreign receiving DFI unable to settle", false, false},
+		{"R84", "Entry not processed by gateway", false, false},
+		{"R85", "Incorrectly coded outbound international payment", false, false},
+	} {
+		achReturnCodes[rc.Code] = rc
+	}
+}
+
+// lookupReturnCode returns the catalog entry for a return code such as "R01"
+func lookupReturnCode(code string) (achReturnCode, bool) {
+	rc, ok := achReturnCodes[strings.ToUpper(strings.TrimSpace(code))]
+	return rc, ok
+}
+
+// transferFailure is why a transfer failed, combining Dwolla's failure resource with the return code catalog
+type transferFailure struct {
+	Code                   string `json:"code"`
+	Description            string `json:"description"`
+	Explanation            string `json:"explanation,omitempty"`
+	Retryable              bool   `json:"retryable"`
+	DisableFundingSource   bool   `json:"disable_funding_source"`
+	FailedFundingSourceURL string `json:"failed_funding_source_url,omitempty"`
+	CustomerURL            string `json:"customer_url,omitempty"`
+	Created                string `json:"created,omitempty"`
+}
+
+// fetchTransferFailure gets the failure resource of a failed transfer from Dwolla
+func fetchTransferFailure(transferURL string) (transferFailure, int, error) {
+	result, status, err := makeDwollaRequest("GET", transferURL+"/failure", nil)
+	if err != nil {
+		return transferFailure{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return transferFailure{}, status, fmt.Errorf("failed to get transfer failure: %v", result)
+	}
+
+	code, _ := result["code"].(string)
+	description, _ := result["description"].(string)
+	explanation, _ := result["explanation"].(string)
+	created, _ := result["created"].(string)
+	links, _ := result["_links"].(map[string]interface{})
+
+	failure := transferFailure{
+		Code:                   code,
+		Description:            description,
+		Explanation:            explanation,
+		FailedFundingSourceURL: linkHref(links, "failed-funding-source"),
+		CustomerURL:            linkHref(links, "customer"),
+		Created:                created,
+	}
+	if rc, ok := lookupReturnCode(code); ok {
+		failure.Retryable = rc.Retryable
+		failure.DisableFundingSource = rc.DisableFundingSource
+		if failure.Description == "" {
+			failure.Description = rc.Description
+		}
+	}
+	return failure, http.StatusOK, nil
+}
+
+// recordTransferFailure fetches and stores the failure reason of a failed transfer created through this service
+func recordTransferFailure(transferID string) {
+	record, ok := getTransferRecord(transferID)
+	if !ok {
+		return
+	}
+
+	failure, _, err := fetchTransferFailure(record.Href)
+	if err != nil {
+		log.Printf("❌ Failed to get failure reason for transfer %s: %v\n", transferID, err)
+		return
+	}
+	setTransferFailure(transferID, failure)
+
+	fmt.Printf("❌ Transfer %s failed with %s: %s\n", transferID, failure.Code, failure.Description)
+	if failure.DisableFundingSource && failure.FailedFundingSourceURL != "" {
+		fmt.Printf("⚠ Return code %s: funding source %s should not be used again\n", failure.Code, failure.FailedFundingSourceURL)
+	}
+
+	scheduleTransferRetry(record, failure)
+}
+
+// setTransferFailure stores the failure reason on the local record of a transfer
+func setTransferFailure(transferID string, failure transferFailure) {
+	transferMutex.Lock()
+	defer transferMutex.Unlock()
+	if record, ok := transferRecords[transferID]; ok {
+		record.Failure = &failure
+	}
+}
+
+// getTransferFailure returns why a transfer failed
+// GET /api/dwolla/transfer/:id/failure
+func getTransferFailure(c *gin.Context) {
+	transferID, transferURL, err := resolveResource(resourceTransfers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	failure, status, err := fetchTransferFailure(transferURL)
+	if err != nil {
+		if status == http.StatusNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "transfer has not failed: " + transferID})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	setTransferFailure(transferID, failure)
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer_id": transferID,
+		"failure":     failure,
+	})
+}
+
+// listReturnCodes returns the ACH return code catalog, optionally filtered by ?retryable= or ?disable_funding_source=
+// GET /api/dwolla/return-codes
+func listReturnCodes(c *gin.Context) {
+	codes := []achReturnCode{}
+	for _, rc := range achReturnCodes {
+		if v := c.Query("retryable"); v != "" && (v == "true") != rc.Retryable {
+			continue
+		}
+		if v := c.Query("disable_funding_source"); v != "" && (v == "true") != rc.DisableFundingSource {
+			continue
+		}
+		codes = append(codes, rc)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(codes),
+		"codes": codes,
+	})
+}
+
+// getReturnCode looks up one ACH return code
+// GET /api/dwolla/return-codes/:code
+func getReturnCode(c *gin.Context) {
+	rc, ok := lookupReturnCode(c.Param("code"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown return code: " + c.Param("code")})
+		return
+	}
+	c.JSON(http.StatusOK, rc)
+}
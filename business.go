@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dwolla business types for verified business customers
+const (
+	businessTypeSoleProprietorship = "soleProprietorship"
+	businessTypeCorporation        = "corporation"
+	businessTypeLLC                = "llc"
+	businessTypePartnership        = "partnership"
+)
+
+var (
+	einPattern     = regexp.MustCompile(`^\d{2}-?\d{7}$`)
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// personAddress is the address shape Dwolla uses for controllers and beneficial owners
+type personAddress struct {
+	Address1            string `json:"address1"`
+	Address2            string `json:"address2,omitempty"`
+	Address3            string `json:"address3,omitempty"`
+	City                string `json:"city"`
+	StateProvinceRegion string `json:"stateProvinceRegion"`
+	PostalCode          string `json:"postalCode,omitempty"`
+	Country             string `json:"country"`
+}
+
+// validate checks the fields Dwolla requires on a controller or owner address
+func (a personAddress) validate() error {
+	if strings.TrimSpace(a.Address1) == "" {
+		return fmt.Errorf("address.address1 is required")
+	}
+	if strings.TrimSpace(a.City) == "" {
+		return fmt.Errorf("address.city is required")
+	}
+	if strings.TrimSpace(a.StateProvinceRegion) == "" {
+		return fmt.Errorf("address.stateProvinceRegion is required")
+	}
+	if !countryPattern.MatchString(a.Country) {
+		return fmt.Errorf("address.country must be a two-letter ISO country code")
+	}
+	if a.Country == "US" && !postalCodePattern.MatchString(a.PostalCode) {
+		return fmt.Errorf("address.postalCode must be a 5 or 9 digit US ZIP code")
+	}
+	return nil
+}
+
+// verifiedPerson is a controller or beneficial owner of a business customer
+type verifiedPerson struct {
+	FirstName   string        `json:"firstName"`
+	LastName    string        `json:"lastName"`
+	Title       string        `json:"title"`
+	DateOfBirth string        `json:"dateOfBirth"`
+	SSN         sensitiveSSN  `json:"ssn"`
+	Address     personAddress `json:"address"`
+}
+
+// validate checks a controller or owner. Titles are only required for controllers.
+func (p verifiedPerson) validate(requireTitle bool) error {
+	if strings.TrimSpace(p.FirstName) == "" || strings.TrimSpace(p.LastName) == "" {
+		return fmt.Errorf("firstName and lastName are required")
+	}
+	if requireTitle && strings.TrimSpace(p.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if err := validateDateOfBirth(p.DateOfBirth); err != nil {
+		return err
+	}
+	if err := validateSSN(p.SSN); err != nil {
+		return err
+	}
+	return p.Address.validate()
+}
+
+// payload converts the person into a Dwolla request body
+func (p verifiedPerson) payload(includeTitle bool) map[string]interface{} {
+	payload := map[string]interface{}{
+		"firstName":   p.FirstName,
+		"lastName":    p.LastName,
+		"dateOfBirth": p.DateOfBirth,
+		"ssn":         p.SSN.digits(),
+		"address":     p.Address,
+	}
+	if includeTitle {
+		payload["title"] = p.Title
+	}
+	return payload
+}
+
+// createBusinessCustomer creates a Dwolla business verified customer
+// POST /api/dwolla/business-customer
+func createBusinessCustomer(c *gin.Context) {
+	var reqBody struct {
+		FirstName              string          `json:"firstName" binding:"required"`
+		LastName               string          `json:"lastName" binding:"required"`
+		Email                  string          `json:"email" binding:"required"`
+		BusinessName           string          `json:"businessName" binding:"required"`
+		BusinessType           string          `json:"businessType" binding:"required"`
+		BusinessClassification string          `json:"businessClassification" binding:"required"`
+		DoingBusinessAs        string          `json:"doingBusinessAs"`
+		EIN                    string          `json:"ein"`
+		Website                string          `json:"website"`
+		DateOfBirth            string          `json:"dateOfBirth"` // sole proprietors only
+		SSN                    sensitiveSSN    `json:"ssn"`         // sole proprietors only
+		Controller             *verifiedPerson `json:"controller"`
+		customerAddress
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := reqBody.customerAddress.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !resourceIDPattern.MatchString(reqBody.BusinessClassification) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "businessClassification must be an industry classification ID"})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"firstName":              reqBody.FirstName,
+		"lastName":               reqBody.LastName,
+		"email":                  reqBody.Email,
+		"type":                   "business",
+		"businessName":           reqBody.BusinessName,
+		"businessType":           reqBody.BusinessType,
+		"businessClassification": reqBody.BusinessClassification,
+	}
+	reqBody.customerAddress.payload(payload)
+	if reqBody.DoingBusinessAs != "" {
+		payload["doingBusinessAs"] = reqBody.DoingBusinessAs
+	}
+	if reqBody.Website != "" {
+		payload["website"] = reqBody.Website
+	}
+	if reqBody.EIN != "" {
+		if !einPattern.MatchString(reqBody.EIN) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ein must be 9 digits"})
+			return
+		}
+		payload["ein"] = strings.ReplaceAll(reqBody.EIN, "-", "")
+	}
+
+	switch reqBody.BusinessType {
+	case businessTypeSoleProprietorship:
+		// Sole proprietors verify with the owner's own identity and have no controller
+		if err := validateDateOfBirth(reqBody.DateOfBirth); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateSSN(reqBody.SSN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		payload["dateOfBirth"] = reqBody.DateOfBirth
+		payload["ssn"] = reqBody.SSN.digits()
+	case businessTypeCorporation, businessTypeLLC, businessTypePartnership:
+		if reqBody.EIN == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ein is required for " + reqBody.BusinessType})
+			return
+		}
+		if reqBody.Controller == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "controller is required for " + reqBody.BusinessType})
+			return
+		}
+		if err := reqBody.Controller.validate(true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid controller: " + err.Error()})
+			return
+		}
+		payload["controller"] = reqBody.Controller.payload(true)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "businessType must be 'soleProprietorship', 'corporation', 'llc' or 'partnership'"})
+		return
+	}
+
+	url := DWOLLA_BASE_URL + "/customers"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to create business customer", "details": result})
+		return
+	}
+
+	customerURL := result["location"].(string)
+	fmt.Printf("Created business customer (%s): %s\n", reqBody.BusinessType, customerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  resourceIDFromHref(customerURL),
+		"customer_url": customerURL,
+		"type":         "business",
+		"status":       "created",
+	})
+}
+
+// listBusinessClassifications returns Dwolla's industry classifications, optionally filtered by name
+// GET /api/dwolla/business-classifications?search=software
+func listBusinessClassifications(c *gin.Context) {
+	url := DWOLLA_BASE_URL + "/business-classifications"
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list business classifications", "details": result})
+		return
+	}
+
+	search := strings.ToLower(strings.TrimSpace(c.Query("search")))
+	classifications := []gin.H{}
+
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	categories, _ := embedded["business-classifications"].([]interface{})
+	for _, rawCategory := range categories {
+		category, ok := rawCategory.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		categoryName, _ := category["name"].(string)
+
+		categoryEmbedded, _ := category["_embedded"].(map[string]interface{})
+		industries, _ := categoryEmbedded["industry-classifications"].([]interface{})
+		for _, rawIndustry := range industries {
+			industry, ok := rawIndustry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := industry["id"].(string)
+			name, _ := industry["name"].(string)
+
+			if search != "" &&
+				!strings.Contains(strings.ToLower(name), search) &&
+				!strings.Contains(strings.ToLower(categoryName), search) {
+				continue
+			}
+
+			classifications = append(classifications, gin.H{
+				"id":       id,
+				"name":     name,
+				"category": categoryName,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"classifications": classifications,
+		"total":           len(classifications),
+	})
+}
+
+// addBeneficialOwner adds a beneficial owner to a business customer
+// POST /api/dwolla/customer/:id/beneficial-owners
+func addBeneficialOwner(c *gin.Context) {
+	_, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var owner verifiedPerson
+	if err := c.BindJSON(&owner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateOwner(owner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := customerURL + "/beneficial-owners"
+	result, status, err := makeDwollaRequest("POST", url, owner.payload(false))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to add beneficial owner", "details": result})
+		return
+	}
+
+	ownerURL := result["location"].(string)
+	fmt.Printf("Added beneficial owner: %s\n", ownerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"beneficial_owner_id":  resourceIDFromHref(ownerURL),
+		"beneficial_owner_url": ownerURL,
+		"status":               "created",
+	})
+}
+
+// listBeneficialOwners lists the beneficial owners of a business customer
+// GET /api/dwolla/customer/:id/beneficial-owners
+func listBeneficialOwners(c *gin.Context) {
+	_, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := customerURL + "/beneficial-owners"
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list beneficial owners", "details": result})
+		return
+	}
+
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	owners, _ := embedded["beneficial-owners"].([]interface{})
+	if owners == nil {
+		owners = []interface{}{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"beneficial_owners": owners,
+		"total":             len(owners),
+	})
+}
+
+// updateBeneficialOwner updates an owner whose verification is incomplete
+// POST /api/dwolla/beneficial-owner/:id
+func updateBeneficialOwner(c *gin.Context) {
+	ownerID, ownerURL, err := resolveResource(resourceOwners, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var owner verifiedPerson
+	if err := c.BindJSON(&owner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateOwner(owner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("POST", ownerURL, owner.payload(false))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to update beneficial owner", "details": result})
+		return
+	}
+
+	fmt.Printf("Updated beneficial owner: %s\n", ownerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"beneficial_owner_id":  ownerID,
+		"beneficial_owner_url": ownerURL,
+		"verification_status":  result["verificationStatus"],
+		"status":               "updated",
+	})
+}
+
+// removeBeneficialOwner removes a beneficial owner from a business customer
+// DELETE /api/dwolla/beneficial-owner/:id
+func removeBeneficialOwner(c *gin.Context) {
+	ownerID, ownerURL, err := resolveResource(resourceOwners, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("DELETE", ownerURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK && status != http.StatusNoContent {
+		c.JSON(status, gin.H{"error": "Failed to remove beneficial owner", "details": result})
+		return
+	}
+
+	fmt.Printf("Removed beneficial owner: %s\n", ownerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"beneficial_owner_id": ownerID,
+		"status":              "deleted",
+	})
+}
+
+// getBeneficialOwnership returns the ownership certification status of a business customer
+// GET /api/dwolla/customer/:id/beneficial-ownership
+func getBeneficialOwnership(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := customerURL + "/beneficial-ownership"
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to get beneficial ownership status", "details": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id": customerID,
+		"status":      result["status"],
+	})
+}
+
+// certifyBeneficialOwnership certifies that all beneficial owners have been added
+// POST /api/dwolla/customer/:id/beneficial-ownership/certify
+func certifyBeneficialOwnership(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"status": "certified",
+	}
+
+	url := customerURL + "/beneficial-ownership"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to certify beneficial ownership", "details": result})
+		return
+	}
+
+	fmt.Printf("✓ Certified beneficial ownership for customer: %s\n", customerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id": customerID,
+		"status":      result["status"],
+	})
+}
+
+// validateOwner checks a beneficial owner, who must provide a full nine-digit SSN
+func validateOwner(owner verifiedPerson) error {
+	if err := owner.validate(false); err != nil {
+		return err
+	}
+	if len(owner.SSN.digits()) != 9 {
+		return fmt.Errorf("beneficial owners must provide the full 9 digit ssn")
+	}
+	return nil
+}
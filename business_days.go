@@ -0,0 +1,79 @@
+package main
+
+import "time"
+
+// isBusinessDay reports whether ACH settles on the given date: a weekday that is not a Federal Reserve holiday
+func isBusinessDay(date time.Time) bool {
+	switch date.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !isFederalReserveHoliday(date)
+}
+
+// nextBusinessDay returns the date itself when it is a business day, otherwise the next one
+func nextBusinessDay(date time.Time) time.Time {
+	for !isBusinessDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// addBusinessDays moves a date forward by the given number of business days
+func addBusinessDays(date time.Time, days int) time.Time {
+	for days > 0 {
+		date = date.AddDate(0, 0, 1)
+		if isBusinessDay(date) {
+			days--
+		}
+	}
+	return date
+}
+
+// isFederalReserveHoliday reports whether the Federal Reserve is closed on the given date.
+// Holidays falling on a Sunday are observed the following Monday; the Federal Reserve
+// does not observe holidays that fall on a Saturday.
+func isFederalReserveHoliday(date time.Time) bool {
+	year, month, day := date.Date()
+
+	// Fixed-date holidays, also matching the Monday after one that falls on a Sunday
+	fixed := []struct {
+		month time.Month
+		day   int
+	}{
+		{time.January, 1},   // New Year's Day
+		{time.June, 19},     // Juneteenth
+		{time.July, 4},      // Independence Day
+		{time.November, 11}, // Veterans Day
+		{time.December, 25}, // Christmas Day
+	}
+	for _, holiday := range fixed {
+		if month == holiday.month && day == holiday.day {
+			return true
+		}
+		observed := time.Date(year, holiday.month, holiday.day, 0, 0, 0, 0, date.Location())
+		if observed.Weekday() == time.Sunday {
+			observed = observed.AddDate(0, 0, 1)
+			if month == observed.Month() && day == observed.Day() {
+				return true
+			}
+		}
+	}
+
+	// Floating holidays
+	switch {
+	case month == time.January && date.Weekday() == time.Monday && (day-1)/7 == 2: // Martin Luther King Jr. Day
+		return true
+	case month == time.February && date.Weekday() == time.Monday && (day-1)/7 == 2: // Washington's Birthday
+		return true
+	case month == time.May && date.Weekday() == time.Monday && day+7 > 31: // Memorial Day
+		return true
+	case month == time.September && date.Weekday() == time.Monday && day <= 7: // Labor Day
+		return true
+	case month == time.October && date.Weekday() == time.Monday && (day-1)/7 == 1: // Columbus Day
+		return true
+	case month == time.November && date.Weekday() == time.Thursday && (day-1)/7 == 3: // Thanksgiving Day
+		return true
+	}
+	return false
+}
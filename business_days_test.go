@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// day parses a YYYY-MM-DD date in UTC for tests
+func day(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := time.Parse(scheduleDateLayout, value)
+	if err != nil {
+		t.Fatalf("bad test date %q: %v", value, err)
+	}
+	return date
+}
+
+func TestIsFederalReserveHoliday(t *testing.T) {
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2025-01-01", true},  // New Year's Day
+		{"2025-01-20", true},  // Martin Luther King Jr. Day, third Monday
+		{"2025-01-13", false}, // second Monday
+		{"2025-02-17", true},  // Washington's Birthday
+		{"2025-05-26", true},  // Memorial Day, last Monday
+		{"2025-05-19", false},
+		{"2025-06-19", true}, // Juneteenth
+		{"2025-07-04", true}, // Independence Day
+		{"2025-09-01", true}, // Labor Day
+		{"2025-10-13", true}, // Columbus Day
+		{"2025-11-11", true}, // Veterans Day
+		{"2025-11-27", true}, // Thanksgiving Day
+		{"2025-11-28", false},
+		{"2025-12-25", true},  // Christmas Day
+		{"2022-12-26", true},  // Christmas on a Sunday, observed Monday
+		{"2023-01-02", true},  // New Year's Day on a Sunday, observed Monday
+		{"2021-06-18", false}, // Juneteenth on a Saturday is not observed on Friday
+		{"2026-07-03", false}, // Independence Day on a Saturday
+		{"2025-03-14", false},
+	}
+
+	for _, tt := range tests {
+		if got := isFederalReserveHoliday(day(t, tt.date)); got != tt.want {
+			t.Errorf("isFederalReserveHoliday(%s) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestNextBusinessDay(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2025-03-14", "2025-03-14"}, // Friday
+		{"2025-03-15", "2025-03-17"}, // Saturday
+		{"2025-03-16", "2025-03-17"}, // Sunday
+		{"2025-08-30", "2025-09-02"}, // Saturday before Labor Day
+		{"2025-12-25", "2025-12-26"}, // Christmas Day
+		{"2022-12-24", "2022-12-27"}, // Saturday, Sunday Christmas, observed Monday
+	}
+
+	for _, tt := range tests {
+		if got := nextBusinessDay(day(t, tt.date)).Format(scheduleDateLayout); got != tt.want {
+			t.Errorf("nextBusinessDay(%s) = %s, want %s", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	tests := []struct {
+		date string
+		days int
+		want string
+	}{
+		{"2025-03-13", 0, "2025-03-13"},
+		{"2025-03-13", 1, "2025-03-14"},
+		{"2025-03-13", 2, "2025-03-17"}, // over a weekend
+		{"2025-11-26", 1, "2025-11-28"}, // over Thanksgiving
+		{"2025-12-31", 1, "2026-01-02"}, // over New Year's Day
+	}
+
+	for _, tt := range tests {
+		if got := addBusinessDays(day(t, tt.date), tt.days).Format(scheduleDateLayout); got != tt.want {
+			t.Errorf("addBusinessDays(%s, %d) = %s, want %s", tt.date, tt.days, got, tt.want)
+		}
+	}
+}
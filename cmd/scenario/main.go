@@ -0,0 +1,496 @@
+// Command scenario runs declarative YAML scenarios against the Dwolla transfer demo service
+// and prints a pass/fail report.
+//
+//	go run ./cmd/scenario -url http://localhost:8001 cmd/scenario/scenarios/*.yaml
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runner executes scenarios against one service
+type runner struct {
+	baseURL string
+	client  *http.Client
+	verbose bool
+}
+
+// stepResult is the outcome of one step
+type stepResult struct {
+	name     string
+	passed   bool
+	skipped  bool
+	err      error
+	duration time.Duration
+}
+
+// scenarioResult is the outcome of one scenario
+type scenarioResult struct {
+	name     string
+	file     string
+	steps    []stepResult
+	err      error
+	duration time.Duration
+}
+
+func (r scenarioResult) passed() bool {
+	if r.err != nil {
+		return false
+	}
+	for _, s := range r.steps {
+		if !s.passed {
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+	baseURL := flag.String("url", envOr("DWOLLA_URL", "http://localhost:8001"), "base URL of the Dwolla service")
+	verbose := flag.Bool("v", false, "print request and response bodies")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: scenario [-url URL] [-v] scenario.yaml...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r := &runner{
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		verbose: *verbose,
+	}
+
+	var results []scenarioResult
+	for _, file := range flag.Args() {
+		s, err := loadScenario(file)
+		if err != nil {
+			results = append(results, scenarioResult{name: file, file: file, err: err})
+			fmt.Printf("\n▶ %s\n  ❌ %v\n", file, err)
+			continue
+		}
+		results = append(results, r.run(s))
+	}
+
+	if !printReport(results) {
+		os.Exit(1)
+	}
+}
+
+// envOr returns an environment variable or a default
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// run executes the steps of a scenario in order, skipping the rest after a failure
+func (r *runner) run(s *scenario) scenarioResult {
+	fmt.Printf("\n▶ %s (%s)\n", s.Name, s.file)
+
+	start := time.Now()
+	result := scenarioResult{name: s.Name, file: s.file}
+
+	vars, err := expandVars(s.Vars, start)
+	if err != nil {
+		result.err = err
+		fmt.Printf("  ❌ %v\n", result.err)
+		return result
+	}
+
+	var deadline time.Time
+	if s.Timeout > 0 {
+		deadline = start.Add(time.Duration(s.Timeout))
+	}
+
+	// Webhooks received before the scenario started never satisfy its waits
+	seen, err := r.webhookIDs()
+	if err != nil {
+		result.err = err
+		fmt.Printf("  ❌ %v\n", err)
+		return result
+	}
+
+	failed := false
+	for _, st := range s.Steps {
+		if failed {
+			result.steps = append(result.steps, stepResult{name: st.Name, skipped: true})
+			fmt.Printf("  - %s (skipped)\n", st.Name)
+			continue
+		}
+
+		stepStart := time.Now()
+		err := r.runStep(st, vars, seen, deadline)
+		sr := stepResult{name: st.Name, passed: err == nil, err: err, duration: time.Since(stepStart)}
+		result.steps = append(result.steps, sr)
+
+		if err != nil {
+			failed = true
+			fmt.Printf("  ✗ %s (%s): %v\n", st.Name, sr.duration.Round(time.Millisecond), err)
+		} else {
+			fmt.Printf("  ✓ %s (%s)\n", st.Name, sr.duration.Round(time.Millisecond))
+		}
+	}
+
+	result.duration = time.Since(start)
+	return result
+}
+
+// expandVars expands a scenario's vars in document order, after the built-in timestamp
+func expandVars(defined variables, start time.Time) (map[string]string, error) {
+	vars := map[string]string{
+		"timestamp": strconv.FormatInt(start.Unix(), 10),
+	}
+	for _, v := range defined {
+		expanded, err := expand(v.Value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("vars.%s: %w", v.Name, err)
+		}
+		vars[v.Name] = expanded
+	}
+	return vars, nil
+}
+
+// runStep executes one step
+func (r *runner) runStep(st step, vars map[string]string, seen map[string]bool, deadline time.Time) error {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return fmt.Errorf("scenario timeout exceeded")
+	}
+
+	switch {
+	case st.Request != nil:
+		return r.runRequest(st, vars)
+	case st.Wait != nil:
+		return r.runWait(st.Wait, vars, seen, deadline)
+	default:
+		time.Sleep(time.Duration(st.Sleep))
+		return nil
+	}
+}
+
+// runRequest sends a request, checks the status and expectations and saves variables
+func (r *runner) runRequest(st step, vars map[string]string) error {
+	req := st.Request
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	path, err := expand(req.Path, vars)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	contentType := req.ContentType
+	if req.Raw != "" {
+		raw, err := expand(req.Raw, vars)
+		if err != nil {
+			return err
+		}
+		body = []byte(raw)
+	} else if req.Body != nil {
+		expanded, err := expandValue(req.Body, vars)
+		if err != nil {
+			return err
+		}
+		body, err = json.Marshal(expanded)
+		if err != nil {
+			return err
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+
+	headers := map[string]string{}
+	for name, value := range req.Headers {
+		expanded, err := expand(value, vars)
+		if err != nil {
+			return err
+		}
+		headers[name] = expanded
+	}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+
+	status, response, err := r.do(method, path, headers, body)
+	if err != nil {
+		return err
+	}
+
+	expectStatus := st.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	if status != expectStatus {
+		return fmt.Errorf("%s %s returned %d, expected %d: %s", method, path, status, expectStatus, compact(response))
+	}
+
+	for path, want := range st.Expect {
+		want, err := expand(want, vars)
+		if err != nil {
+			return err
+		}
+		got, ok := lookupPath(response, path)
+		if !ok {
+			return fmt.Errorf("response has no %s", path)
+		}
+		if stringValue(got) != want {
+			return fmt.Errorf("%s is %q, expected %q", path, stringValue(got), want)
+		}
+	}
+
+	for name, path := range st.Save {
+		value, ok := lookupPath(response, path)
+		if !ok {
+			return fmt.Errorf("cannot save %s: response has no %s", name, path)
+		}
+		vars[name] = stringValue(value)
+	}
+	return nil
+}
+
+// runWait polls until the webhook or transfer status appears or the timeout passes
+func (r *runner) runWait(w *waitStep, vars map[string]string, seen map[string]bool, deadline time.Time) error {
+	timeout := time.Duration(w.Timeout)
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
+	stepDeadline := time.Now().Add(timeout)
+	if !deadline.IsZero() && deadline.Before(stepDeadline) {
+		stepDeadline = deadline
+	}
+
+	var check func() (bool, string, error)
+	var description string
+
+	if w.Webhook != nil {
+		resource, err := expand(w.Webhook.Resource, vars)
+		if err != nil {
+			return err
+		}
+		description = "webhook " + w.Webhook.Topic
+		if resource != "" {
+			description += " for " + resource
+		}
+		check = func() (bool, string, error) {
+			return r.findWebhook(w.Webhook.Topic, resource, seen)
+		}
+	} else {
+		transfer, err := expand(w.TransferStatus.Transfer, vars)
+		if err != nil {
+			return err
+		}
+		id := transfer[strings.LastIndex(transfer, "/")+1:]
+		description = fmt.Sprintf("transfer %s to be %s", id, w.TransferStatus.Status)
+		check = func() (bool, string, error) {
+			status, response, err := r.do(http.MethodGet, "/api/dwolla/transfer/"+id, nil, nil)
+			if err != nil {
+				return false, "", err
+			}
+			if status != http.StatusOK {
+				return false, fmt.Sprintf("lookup returned %d", status), nil
+			}
+			current, _ := lookupPath(response, "status")
+			return stringValue(current) == w.TransferStatus.Status, "last status " + stringValue(current), nil
+		}
+	}
+
+	last := ""
+	for {
+		done, state, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		last = state
+		if time.Now().After(stepDeadline) {
+			if last != "" {
+				return fmt.Errorf("timed out waiting for %s (%s)", description, last)
+			}
+			return fmt.Errorf("timed out waiting for %s", description)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// findWebhook looks for a new webhook with the topic about the resource ID or URL
+func (r *runner) findWebhook(topic, resource string, seen map[string]bool) (bool, string, error) {
+	events, err := r.webhookEvents()
+	if err != nil {
+		return false, "", err
+	}
+
+	topics := []string{}
+	for _, event := range events {
+		id := stringValue(event["id"])
+		if seen[id] {
+			continue
+		}
+		eventTopic := stringValue(event["topic"])
+		topics = append(topics, eventTopic)
+		if eventTopic != topic {
+			continue
+		}
+		if resource == "" {
+			return true, "", nil
+		}
+
+		href, _ := lookupPath(event, "_links.resource.href")
+		resourceID := stringValue(event["resourceId"])
+		if resourceID == resource || stringValue(href) == resource || (resourceID != "" && strings.HasSuffix(resource, "/"+resourceID)) {
+			return true, "", nil
+		}
+	}
+
+	if len(topics) == 0 {
+		return false, "no new webhooks", nil
+	}
+	return false, "received " + strings.Join(topics, ", "), nil
+}
+
+// webhookEvents returns the webhook events the service has received
+func (r *runner) webhookEvents() ([]map[string]interface{}, error) {
+	status, response, err := r.do(http.MethodGet, "/api/dwolla/webhook-events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("webhook events returned %d", status)
+	}
+
+	list, _ := response.([]interface{})
+	events := []map[string]interface{}{}
+	for _, item := range list {
+		if event, ok := item.(map[string]interface{}); ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// webhookIDs returns the IDs of the webhook events received so far
+func (r *runner) webhookIDs() (map[string]bool, error) {
+	events, err := r.webhookEvents()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read webhook events from %s: %w", r.baseURL, err)
+	}
+	seen := map[string]bool{}
+	for _, event := range events {
+		seen[stringValue(event["id"])] = true
+	}
+	return seen, nil
+}
+
+// do sends a request to the service and decodes a JSON response
+func (r *runner) do(method, path string, headers map[string]string, body []byte) (int, interface{}, error) {
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = r.baseURL + path
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	if r.verbose && body != nil {
+		fmt.Printf("    → %s %s %s\n", method, url, body)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if r.verbose {
+		fmt.Printf("    ← %d %s\n", resp.StatusCode, data)
+	}
+
+	var response interface{}
+	if len(data) > 0 && strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := json.Unmarshal(data, &response); err != nil {
+			return resp.StatusCode, nil, fmt.Errorf("invalid JSON from %s: %w", path, err)
+		}
+	} else if len(data) > 0 {
+		response = string(data)
+	}
+	return resp.StatusCode, response, nil
+}
+
+// compact renders a response for error messages
+func compact(response interface{}) string {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Sprint(response)
+	}
+	if len(data) > 300 {
+		return string(data[:300]) + "..."
+	}
+	return string(data)
+}
+
+// printReport prints the summary and reports whether every scenario passed
+func printReport(results []scenarioResult) bool {
+	passed := 0
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Println("Scenario report")
+	fmt.Println(strings.Repeat("=", 60))
+
+	for _, result := range results {
+		steps := 0
+		for _, s := range result.steps {
+			if s.passed {
+				steps++
+			}
+		}
+		if result.passed() {
+			passed++
+			fmt.Printf("✅ PASS  %s  (%d/%d steps, %s)\n", result.name, steps, len(result.steps), result.duration.Round(time.Millisecond))
+			continue
+		}
+
+		fmt.Printf("❌ FAIL  %s  (%d/%d steps, %s)\n", result.name, steps, len(result.steps), result.duration.Round(time.Millisecond))
+		if result.err != nil {
+			fmt.Printf("         %v\n", result.err)
+		}
+		for _, s := range result.steps {
+			if !s.passed && !s.skipped {
+				fmt.Printf("         %s: %v\n", s.name, s.err)
+			}
+		}
+	}
+
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("%d passed, %d failed, %d total\n", passed, len(results)-passed, len(results))
+	return passed == len(results)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default time a wait step polls before failing
+const defaultWaitTimeout = 30 * time.Second
+
+// scenario is one YAML file describing a flow against the Dwolla service
+type scenario struct {
+	Name    string    `yaml:"name"`
+	Timeout duration  `yaml:"timeout"` // whole scenario, default no limit
+	Vars    variables `yaml:"vars"`
+	Steps   []step    `yaml:"steps"`
+
+	file string
+}
+
+// step is one action of a scenario: an HTTP request, a wait or a pause.
+// expect_status, expect and save apply to the response of a request.
+type step struct {
+	Name         string            `yaml:"name"`
+	Request      *requestStep      `yaml:"request"`
+	Wait         *waitStep         `yaml:"wait"`
+	Sleep        duration          `yaml:"sleep"`
+	ExpectStatus int               `yaml:"expect_status"` // default 200
+	Expect       map[string]string `yaml:"expect"`        // response path -> expected value
+	Save         map[string]string `yaml:"save"`          // variable -> response path
+}
+
+// requestStep is an HTTP request to the service
+type requestStep struct {
+	Method      string            `yaml:"method"`
+	Path        string            `yaml:"path"`
+	Body        interface{}       `yaml:"body"`
+	Raw         string            `yaml:"raw"` // sent as-is instead of body, e.g. CSV
+	ContentType string            `yaml:"content_type"`
+	Headers     map[string]string `yaml:"headers"`
+}
+
+// waitStep polls the service until a webhook arrives or a transfer reaches a status
+type waitStep struct {
+	Webhook        *webhookWait        `yaml:"webhook"`
+	TransferStatus *transferStatusWait `yaml:"transfer_status"`
+	Timeout        duration            `yaml:"timeout"`
+}
+
+// webhookWait matches a received webhook by topic and, optionally, resource ID or URL
+type webhookWait struct {
+	Topic    string `yaml:"topic"`
+	Resource string `yaml:"resource"`
+}
+
+// transferStatusWait waits for a transfer lookup to report a status
+type transferStatusWait struct {
+	Transfer string `yaml:"transfer"` // transfer ID or URL
+	Status   string `yaml:"status"`
+}
+
+// variable is one entry of a scenario's vars
+type variable struct {
+	Name  string
+	Value string
+}
+
+// variables keeps vars in document order, so each can use the ones defined before it
+type variables []variable
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (v *variables) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: vars must be a mapping", value.Line)
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, item := value.Content[i], value.Content[i+1]
+		if seen[key.Value] {
+			return fmt.Errorf("line %d: variable %q is defined twice", key.Line, key.Value)
+		}
+		seen[key.Value] = true
+
+		var text string
+		if err := item.Decode(&text); err != nil {
+			return fmt.Errorf("line %d: variable %q must be a string", item.Line, key.Value)
+		}
+		*v = append(*v, variable{Name: key.Value, Value: text})
+	}
+	return nil
+}
+
+// duration is a time.Duration written as "30s" or "2m" in YAML
+type duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// loadScenario reads and checks a scenario file
+func loadScenario(file string) (*scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject unknown keys so a misspelt expectation cannot silently pass
+	var s scenario
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	s.file = file
+	if s.Name == "" {
+		s.Name = file
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("%s: scenario has no steps", file)
+	}
+
+	for i, st := range s.Steps {
+		kinds := 0
+		if st.Request != nil {
+			kinds++
+		}
+		if st.Wait != nil {
+			kinds++
+			if (st.Wait.Webhook == nil) == (st.Wait.TransferStatus == nil) {
+				return nil, fmt.Errorf("%s: step %d: wait needs exactly one of webhook or transfer_status", file, i+1)
+			}
+		}
+		if st.Sleep > 0 {
+			kinds++
+		}
+		if kinds != 1 {
+			return nil, fmt.Errorf("%s: step %d: needs exactly one of request, wait or sleep", file, i+1)
+		}
+		if st.Request == nil && (st.ExpectStatus != 0 || st.Expect != nil || st.Save != nil) {
+			return nil, fmt.Errorf("%s: step %d: expect_status, expect and save need a request", file, i+1)
+		}
+		if s.Steps[i].Name == "" {
+			s.Steps[i].Name = fmt.Sprintf("step %d", i+1)
+		}
+	}
+	return &s, nil
+}
+
+var templatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+// expand replaces {{name}} with scenario variables and {{env.NAME}} with environment variables
+func expand(text string, vars map[string]string) (string, error) {
+	var missing []string
+	expanded := templatePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := templatePattern.FindStringSubmatch(match)[1]
+		if strings.HasPrefix(name, "env.") {
+			if value, ok := os.LookupEnv(strings.TrimPrefix(name, "env.")); ok {
+				return value
+			}
+		} else if value, ok := vars[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable(s): %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// expandValue expands templates in every string of a decoded YAML value
+func expandValue(value interface{}, vars map[string]string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expand(v, vars)
+	case map[string]interface{}:
+		expanded := map[string]interface{}{}
+		for key, item := range v {
+			e, err := expandValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = e
+		}
+		return expanded, nil
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			e, err := expandValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = e
+		}
+		return expanded, nil
+	}
+	return value, nil
+}
+
+// lookupPath finds a dot separated path such as "_embedded.funding-sources.0.id" in a JSON value
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	if path == "" || path == "." {
+		return value, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			item, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			value = item
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// stringValue formats a JSON value for comparison and variables
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
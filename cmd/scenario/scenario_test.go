@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScenario writes a scenario file into a temporary directory
+func writeScenario(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadScenario(t *testing.T) {
+	file := writeScenario(t, `
+name: Example
+timeout: 2m
+vars:
+  b: "{{a}}-b"
+  a: "1"
+  c: "{{b}}-c"
+steps:
+  - request:
+      method: POST
+      path: /api/dwolla/customer
+    save:
+      customer_url: customer_url
+  - wait:
+      webhook:
+        topic: customer_created
+      timeout: 10s
+  - name: Pause
+    sleep: 1s
+`)
+
+	s, err := loadScenario(file)
+	if err != nil {
+		t.Fatalf("loadScenario() error = %v", err)
+	}
+	if s.Name != "Example" || time.Duration(s.Timeout) != 2*time.Minute {
+		t.Errorf("name, timeout = %q, %v", s.Name, time.Duration(s.Timeout))
+	}
+	wantVars := variables{{"b", "{{a}}-b"}, {"a", "1"}, {"c", "{{b}}-c"}}
+	if !reflect.DeepEqual(s.Vars, wantVars) {
+		t.Errorf("vars = %v, want %v", s.Vars, wantVars)
+	}
+	var names []string
+	for _, st := range s.Steps {
+		names = append(names, st.Name)
+	}
+	if want := []string{"step 1", "step 2", "Pause"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("step names = %v, want %v", names, want)
+	}
+}
+
+func TestBundledScenariosLoad(t *testing.T) {
+	files, err := filepath.Glob("scenarios/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no bundled scenarios: %v", err)
+	}
+	for _, file := range files {
+		if _, err := loadScenario(file); err != nil {
+			t.Errorf("loadScenario(%s) error = %v", file, err)
+		}
+	}
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"no steps", "name: x\n", "scenario has no steps"},
+		{"unknown key", "steps:\n  - sleep: 1s\n    expcet: {}\n", "field expcet not found"},
+		{"bad duration", "steps:\n  - sleep: soon\n", "invalid duration"},
+		{"two kinds", "steps:\n  - sleep: 1s\n    request:\n      path: /\n", "needs exactly one of request, wait or sleep"},
+		{"no kind", "steps:\n  - name: nothing\n", "needs exactly one of request, wait or sleep"},
+		{"empty wait", "steps:\n  - wait:\n      timeout: 1s\n", "wait needs exactly one of webhook or transfer_status"},
+		{"expect without request", "steps:\n  - sleep: 1s\n    expect_status: 200\n", "need a request"},
+		{"vars not a mapping", "vars: [a]\nsteps:\n  - sleep: 1s\n", "vars must be a mapping"},
+		{"duplicate var", "vars:\n  a: \"1\"\n  a: \"2\"\nsteps:\n  - sleep: 1s\n", `variable "a" is defined twice`},
+		{"var not a string", "vars:\n  a: [1]\nsteps:\n  - sleep: 1s\n", `variable "a" must be a string`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadScenario(writeScenario(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadScenario() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("SCENARIO_TEST_URL", "https://example.com")
+	vars := map[string]string{"id": "42", "amount": "10.00"}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"plain", "no templates", "no templates", ""},
+		{"variable", "/transfer/{{id}}", "/transfer/42", ""},
+		{"spaces", "{{ amount }} USD", "10.00 USD", ""},
+		{"env", "{{env.SCENARIO_TEST_URL}}/x", "https://example.com/x", ""},
+		{"missing", "{{id}} {{nope}} {{env.SCENARIO_TEST_UNSET}}", "", "nope, env.SCENARIO_TEST_UNSET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expand(tt.text, vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expand() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		defined variables
+		want    map[string]string
+		wantErr string
+	}{
+		{"timestamp only", nil, map[string]string{"timestamp": "1700000000"}, ""},
+		{"uses earlier vars", variables{{"a", "1"}, {"b", "{{a}}-b"}, {"email", "x+{{timestamp}}@example.com"}},
+			map[string]string{"timestamp": "1700000000", "a": "1", "b": "1-b", "email": "x+1700000000@example.com"}, ""},
+		{"later var is not yet defined", variables{{"b", "{{a}}-b"}, {"a", "1"}}, nil, "vars.b: undefined variable(s): a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeat to catch any dependence on map iteration order
+			for i := 0; i < 20; i++ {
+				got, err := expandVars(tt.defined, start)
+				if tt.wantErr != "" {
+					if err == nil || err.Error() != tt.wantErr {
+						t.Fatalf("expandVars() error = %v, want %q", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("expandVars() = %v, %v, want %v", got, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandValue(t *testing.T) {
+	body := map[string]interface{}{
+		"amount": map[string]interface{}{"value": "{{amount}}", "currency": "USD"},
+		"tags":   []interface{}{"{{id}}", 7},
+	}
+	want := map[string]interface{}{
+		"amount": map[string]interface{}{"value": "10.00", "currency": "USD"},
+		"tags":   []interface{}{"42", 7},
+	}
+
+	got, err := expandValue(body, map[string]string{"id": "42", "amount": "10.00"})
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("expandValue() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	value := map[string]interface{}{
+		"amount": map[string]interface{}{"value": "10.00"},
+		"_embedded": map[string]interface{}{
+			"funding-sources": []interface{}{
+				map[string]interface{}{"id": "first"},
+				map[string]interface{}{"id": "second"},
+			},
+		},
+	}
+
+	tests := []struct {
+		path   string
+		want   interface{}
+		wantOK bool
+	}{
+		{"amount.value", "10.00", true},
+		{"_embedded.funding-sources.1.id", "second", true},
+		{"_embedded.funding-sources.2.id", nil, false},
+		{"_embedded.funding-sources.x", nil, false},
+		{"amount.value.deeper", nil, false},
+		{"missing", nil, false},
+		{".", value, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := lookupPath(value, tt.path)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookupPath(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
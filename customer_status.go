@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customerAuditEntry records a compliance action taken against a customer
+type customerAuditEntry struct {
+	CustomerID     string `json:"customer_id"`
+	Action         string `json:"action"`
+	Reason         string `json:"reason"`
+	PerformedBy    string `json:"performed_by,omitempty"`
+	PreviousStatus string `json:"previous_status"`
+	NewStatus      string `json:"new_status"`
+	Timestamp      string `json:"timestamp"`
+}
+
+var (
+	// Customers suspended by this service. Dwolla does not allow suspending
+	// customers through the API, so suspension is enforced locally.
+	suspendedCustomers = map[string]bool{}
+
+	// Compliance audit trail, oldest first
+	customerAuditLog []customerAuditEntry
+	customerMutex    sync.RWMutex
+)
+
+// customerStatusRequest is the body for deactivate, suspend and reactivate
+type customerStatusRequest struct {
+	Reason      string `json:"reason" binding:"required"`
+	PerformedBy string `json:"performed_by"`
+}
+
+// recordCustomerAudit appends an entry to the audit trail
+func recordCustomerAudit(entry customerAuditEntry) {
+	entry.Timestamp = time.Now().Format(time.RFC3339)
+
+	customerMutex.Lock()
+	customerAuditLog = append(customerAuditLog, entry)
+	customerMutex.Unlock()
+
+	fmt.Printf("📝 Audit: %s customer %s (%s -> %s): %s\n",
+		entry.Action, entry.CustomerID, entry.PreviousStatus, entry.NewStatus, entry.Reason)
+}
+
+// isCustomerSuspended reports whether a customer is locally suspended
+func isCustomerSuspended(customerID string) bool {
+	customerMutex.RLock()
+	defer customerMutex.RUnlock()
+	return suspendedCustomers[customerID]
+}
+
+// effectiveCustomerStatus combines the Dwolla status with local suspension
+func effectiveCustomerStatus(cust customer) string {
+	if cust.Status != "deactivated" && isCustomerSuspended(cust.ID) {
+		return "suspended"
+	}
+	return cust.Status
+}
+
+// setDwollaCustomerStatus posts a status change ("deactivated" or "reactivated") to Dwolla
+func setDwollaCustomerStatus(cust customer, status string) (customer, int, error) {
+	payload := map[string]interface{}{
+		"status": status,
+	}
+
+	result, code, err := makeDwollaRequest("POST", cust.Href, payload)
+	if err != nil {
+		return customer{}, http.StatusInternalServerError, err
+	}
+	if code != http.StatusOK {
+		return customer{}, code, fmt.Errorf("failed to set customer status to %s: %v", status, result)
+	}
+
+	updated, err := customerFromResult(result)
+	if err != nil {
+		return customer{}, http.StatusInternalServerError, err
+	}
+	return updated, http.StatusOK, nil
+}
+
+// deactivateCustomer deactivates a customer in Dwolla
+// POST /api/dwolla/customer/:id/deactivate
+func deactivateCustomer(c *gin.Context) {
+	var reqBody customerStatusRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousStatus := effectiveCustomerStatus(cust)
+	if cust.Status == "deactivated" {
+		c.JSON(http.StatusConflict, gin.H{"error": "customer is already deactivated"})
+		return
+	}
+
+	updated, status, err := setDwollaCustomerStatus(cust, "deactivated")
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerMutex.Lock()
+	delete(suspendedCustomers, cust.ID)
+	customerMutex.Unlock()
+
+	recordCustomerAudit(customerAuditEntry{
+		CustomerID:     cust.ID,
+		Action:         "deactivate",
+		Reason:         reqBody.Reason,
+		PerformedBy:    reqBody.PerformedBy,
+		PreviousStatus: previousStatus,
+		NewStatus:      updated.Status,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  updated.ID,
+		"customer_url": updated.Href,
+		"status":       updated.Status,
+	})
+}
+
+// suspendCustomer blocks a customer from transacting through this service
+// POST /api/dwolla/customer/:id/suspend
+func suspendCustomer(c *gin.Context) {
+	var reqBody customerStatusRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousStatus := effectiveCustomerStatus(cust)
+	if previousStatus == "suspended" || previousStatus == "deactivated" {
+		c.JSON(http.StatusConflict, gin.H{"error": "customer is already " + previousStatus})
+		return
+	}
+
+	customerMutex.Lock()
+	suspendedCustomers[cust.ID] = true
+	customerMutex.Unlock()
+
+	recordCustomerAudit(customerAuditEntry{
+		CustomerID:     cust.ID,
+		Action:         "suspend",
+		Reason:         reqBody.Reason,
+		PerformedBy:    reqBody.PerformedBy,
+		PreviousStatus: previousStatus,
+		NewStatus:      "suspended",
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  cust.ID,
+		"customer_url": cust.Href,
+		"status":       "suspended",
+	})
+}
+
+// reactivateCustomer lifts a local suspension and reactivates a deactivated customer in Dwolla
+// POST /api/dwolla/customer/:id/reactivate
+func reactivateCustomer(c *gin.Context) {
+	var reqBody customerStatusRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousStatus := effectiveCustomerStatus(cust)
+	updated := cust
+
+	switch {
+	case cust.Status == "deactivated":
+		updated, status, err = setDwollaCustomerStatus(cust, "reactivated")
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	case cust.Status == "suspended":
+		// Dwolla suspensions can only be lifted by Dwolla support
+		c.JSON(http.StatusConflict, gin.H{"error": "customer is suspended by Dwolla and must be reinstated through Dwolla support"})
+		return
+	case previousStatus != "suspended":
+		c.JSON(http.StatusConflict, gin.H{"error": "customer is not suspended or deactivated", "status": cust.Status})
+		return
+	}
+
+	customerMutex.Lock()
+	delete(suspendedCustomers, cust.ID)
+	customerMutex.Unlock()
+
+	recordCustomerAudit(customerAuditEntry{
+		CustomerID:     cust.ID,
+		Action:         "reactivate",
+		Reason:         reqBody.Reason,
+		PerformedBy:    reqBody.PerformedBy,
+		PreviousStatus: previousStatus,
+		NewStatus:      updated.Status,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  updated.ID,
+		"customer_url": updated.Href,
+		"status":       updated.Status,
+	})
+}
+
+// getCustomerAuditLog returns the compliance audit trail for a customer
+// GET /api/dwolla/customer/:id/audit-log
+func getCustomerAuditLog(c *gin.Context) {
+	customerID, _, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerMutex.RLock()
+	entries := []customerAuditEntry{}
+	for _, entry := range customerAuditLog {
+		if entry.CustomerID == customerID {
+			entries = append(entries, entry)
+		}
+	}
+	suspended := suspendedCustomers[customerID]
+	customerMutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":       customerID,
+		"locally_suspended": suspended,
+		"entries":           entries,
+	})
+}
+
+// checkFundingSourceActive verifies that the customer owning a funding source may transact
+// and returns the funding source. Funding sources owned by the master account are always allowed.
+func checkFundingSourceActive(fundingSourceURL string) (fundingSource, int, error) {
+	fs, status, err := fetchFundingSource(fundingSourceURL)
+	if err != nil {
+		return fundingSource{}, status, err
+	}
+	if fs.CustomerURL == "" {
+		return fs, http.StatusOK, nil
+	}
+
+	cust, status, err := fetchCustomer(fs.CustomerURL)
+	if err != nil {
+		return fundingSource{}, status, err
+	}
+
+	customerStatus := effectiveCustomerStatus(cust)
+	if customerStatus == "suspended" || customerStatus == "deactivated" {
+		name := strings.TrimSpace(cust.FirstName + " " + cust.LastName)
+		return fundingSource{}, http.StatusConflict, fmt.Errorf("customer %s (%s) is %s and cannot transact", cust.ID, name, customerStatus)
+	}
+	return fs, http.StatusOK, nil
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dwolla customer types
+const (
+	customerTypeUnverified  = "unverified"
+	customerTypeReceiveOnly = "receive-only"
+	customerTypePersonal    = "personal"
+)
+
+var (
+	statePattern      = regexp.MustCompile(`^[A-Z]{2}$`)
+	postalCodePattern = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+	ssnPattern        = regexp.MustCompile(`^(\d{4}|\d{9})$`)
+)
+
+// sensitiveSSN holds a social security number (last four or full nine digits).
+// It masks itself under every fmt verb so it can never leak through fmt.Printf or log output.
+type sensitiveSSN string
+
+// Format implements fmt.Formatter
+func (s sensitiveSSN) Format(f fmt.State, verb rune) {
+	io.WriteString(f, s.masked())
+}
+
+// masked returns the SSN with everything but the last four digits hidden
+func (s sensitiveSSN) masked() string {
+	digits := s.digits()
+	if len(digits) < 4 {
+		return "****"
+	}
+	return "***-**-" + digits[len(digits)-4:]
+}
+
+// digits returns the SSN with separators removed
+func (s sensitiveSSN) digits() string {
+	return strings.NewReplacer("-", "", " ", "").Replace(string(s))
+}
+
+// customerAddress is the postal address required for verified customers
+type customerAddress struct {
+	Address1   string `json:"address1"`
+	Address2   string `json:"address2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postalCode"`
+}
+
+// validate checks the address fields Dwolla requires for verification
+func (a customerAddress) validate() error {
+	if strings.TrimSpace(a.Address1) == "" {
+		return fmt.Errorf("address1 is required")
+	}
+	if len(a.Address1) > 50 || len(a.Address2) > 50 {
+		return fmt.Errorf("address lines must be 50 characters or fewer")
+	}
+	if strings.TrimSpace(a.City) == "" {
+		return fmt.Errorf("city is required")
+	}
+	if !statePattern.MatchString(a.State) {
+		return fmt.Errorf("state must be a two-letter uppercase abbreviation")
+	}
+	if !postalCodePattern.MatchString(a.PostalCode) {
+		return fmt.Errorf("postalCode must be a 5 or 9 digit US ZIP code")
+	}
+	return nil
+}
+
+// payload adds the address fields to a Dwolla customer payload
+func (a customerAddress) payload(payload map[string]interface{}) {
+	payload["address1"] = a.Address1
+	if a.Address2 != "" {
+		payload["address2"] = a.Address2
+	}
+	payload["city"] = a.City
+	payload["state"] = a.State
+	payload["postalCode"] = a.PostalCode
+}
+
+// validateDateOfBirth checks a YYYY-MM-DD birth date for an adult customer
+func validateDateOfBirth(dateOfBirth string) error {
+	dob, err := time.Parse("2006-01-02", dateOfBirth)
+	if err != nil {
+		return fmt.Errorf("dateOfBirth must be formatted as YYYY-MM-DD")
+	}
+	if dob.After(time.Now().AddDate(-18, 0, 0)) {
+		return fmt.Errorf("customer must be at least 18 years old")
+	}
+	if dob.Before(time.Now().AddDate(-125, 0, 0)) {
+		return fmt.Errorf("dateOfBirth is too far in the past")
+	}
+	return nil
+}
+
+// validateSSN checks that an SSN is either the last four or all nine digits
+func validateSSN(ssn sensitiveSSN) error {
+	if !ssnPattern.MatchString(ssn.digits()) {
+		return fmt.Errorf("ssn must be the last 4 digits or the full 9 digits")
+	}
+	return nil
+}
+
+// validatePersonalDetails checks the KYC fields of a personal verified customer
+func validatePersonalDetails(address customerAddress, dateOfBirth string, ssn sensitiveSSN) error {
+	if err := address.validate(); err != nil {
+		return err
+	}
+	if err := validateDateOfBirth(dateOfBirth); err != nil {
+		return err
+	}
+	return validateSSN(ssn)
+}
+
+// createCustomer creates a Dwolla customer
+// POST /api/dwolla/customer
+func createCustomer(c *gin.Context) {
+	var reqBody struct {
+		FirstName   string       `json:"firstName" binding:"required"`
+		LastName    string       `json:"lastName" binding:"required"`
+		Email       string       `json:"email" binding:"required"`
+		Type        string       `json:"type"` // "unverified" (default), "receive-only" or "personal"
+		DateOfBirth string       `json:"dateOfBirth"`
+		SSN         sensitiveSSN `json:"ssn"`
+		customerAddress
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerType := reqBody.Type
+	if customerType == "" {
+		customerType = customerTypeUnverified
+	}
+
+	// Create customer payload
+	payload := map[string]interface{}{
+		"firstName": reqBody.FirstName,
+		"lastName":  reqBody.LastName,
+		"email":     reqBody.Email,
+	}
+
+	switch customerType {
+	case customerTypeUnverified:
+		// Dwolla defaults to unverified when no type is sent
+	case customerTypeReceiveOnly:
+		payload["type"] = customerType
+	case customerTypePersonal:
+		if err := validatePersonalDetails(reqBody.customerAddress, reqBody.DateOfBirth, reqBody.SSN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		payload["type"] = customerType
+		payload["dateOfBirth"] = reqBody.DateOfBirth
+		payload["ssn"] = reqBody.SSN.digits()
+		reqBody.customerAddress.payload(payload)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'unverified', 'receive-only' or 'personal'"})
+		return
+	}
+
+	url := DWOLLA_BASE_URL + "/customers"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to create customer", "details": result})
+		return
+	}
+
+	customerURL := result["location"].(string)
+	fmt.Printf("Created %s customer: %s\n", customerType, customerURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  resourceIDFromHref(customerURL),
+		"customer_url": customerURL,
+		"type":         customerType,
+		"status":       "created",
+	})
+}
+
+// Statuses a Dwolla customer can be in
+var customerStatuses = map[string]bool{
+	"unverified":  true,
+	"retry":       true,
+	"document":    true,
+	"verified":    true,
+	"suspended":   true,
+	"deactivated": true,
+}
+
+// customer is the typed view of a Dwolla customer resource
+type customer struct {
+	ID           string `json:"id"`
+	Href         string `json:"href"`
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	Email        string `json:"email"`
+	Type         string `json:"type"`
+	Status       string `json:"status"`
+	BusinessName string `json:"businessName,omitempty"`
+	Address1     string `json:"address1,omitempty"`
+	Address2     string `json:"address2,omitempty"`
+	City         string `json:"city,omitempty"`
+	State        string `json:"state,omitempty"`
+	PostalCode   string `json:"postalCode,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Created      string `json:"created"`
+}
+
+// customerFromResult converts a Dwolla customer response into a customer
+func customerFromResult(result map[string]interface{}) (customer, error) {
+	var cust customer
+	if err := decodeDwollaResource(result, &cust); err != nil {
+		return customer{}, err
+	}
+	cust.Href = selfHref(result)
+	if cust.Href == "" && cust.ID != "" {
+		cust.Href = resourceHref(resourceCustomers, cust.ID)
+	}
+	return cust, nil
+}
+
+// fetchCustomer retrieves a customer by ID or URL
+func fetchCustomer(ref string) (customer, int, error) {
+	_, customerURL, err := resolveResource(resourceCustomers, ref)
+	if err != nil {
+		return customer{}, http.StatusBadRequest, err
+	}
+
+	result, status, err := makeDwollaRequest("GET", customerURL, nil)
+	if err != nil {
+		return customer{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return customer{}, status, fmt.Errorf("failed to get customer: %v", result)
+	}
+
+	cust, err := customerFromResult(result)
+	if err != nil {
+		return customer{}, http.StatusInternalServerError, err
+	}
+	return cust, http.StatusOK, nil
+}
+
+// getCustomer retrieves a single customer
+// GET /api/dwolla/customer/:id
+func getCustomer(c *gin.Context) {
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cust)
+}
+
+// listCustomers lists customers, proxying Dwolla's search, email, status, limit and offset filters
+// GET /api/dwolla/customers?search=jane&status=verified&limit=25&offset=0
+func listCustomers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	if search := c.Query("search"); search != "" {
+		// Dwolla matches search against first name, last name, email and business name
+		query.Set("search", search)
+	}
+	if email := c.Query("email"); email != "" {
+		query.Set("email", email)
+	}
+	if status := c.Query("status"); status != "" {
+		if !customerStatuses[status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status: " + status})
+			return
+		}
+		query.Set("status", status)
+	}
+
+	requestURL := DWOLLA_BASE_URL + "/customers?" + query.Encode()
+	result, status, err := makeDwollaRequest("GET", requestURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list customers", "details": result})
+		return
+	}
+
+	customers := []customer{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawCustomers, _ := embedded["customers"].([]interface{})
+	for _, raw := range rawCustomers {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cust, err := customerFromResult(item)
+		if err != nil {
+			continue
+		}
+		customers = append(customers, cust)
+	}
+
+	total, _ := result["total"].(float64)
+	response := gin.H{
+		"customers": customers,
+		"total":     int(total),
+		"limit":     limit,
+		"offset":    offset,
+	}
+	if offset+len(customers) < int(total) {
+		response["next_offset"] = offset + len(customers)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// updateCustomer updates a customer's email, name, phone or address
+// POST /api/dwolla/customer/:id
+func updateCustomer(c *gin.Context) {
+	var reqBody struct {
+		FirstName    string `json:"firstName"`
+		LastName     string `json:"lastName"`
+		Email        string `json:"email"`
+		BusinessName string `json:"businessName"`
+		Phone        string `json:"phone"`
+		customerAddress
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{}
+	if reqBody.Email != "" {
+		if !strings.Contains(reqBody.Email, "@") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is invalid"})
+			return
+		}
+		payload["email"] = reqBody.Email
+	}
+	if reqBody.Phone != "" {
+		payload["phone"] = reqBody.Phone
+	}
+
+	// Dwolla only allows name changes on unverified and receive-only customers
+	unverified := cust.Type == customerTypeUnverified || cust.Type == customerTypeReceiveOnly
+	if reqBody.FirstName != "" || reqBody.LastName != "" || reqBody.BusinessName != "" {
+		if !unverified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "names can only be changed on unverified customers"})
+			return
+		}
+		if reqBody.FirstName != "" {
+			payload["firstName"] = reqBody.FirstName
+		}
+		if reqBody.LastName != "" {
+			payload["lastName"] = reqBody.LastName
+		}
+		if reqBody.BusinessName != "" {
+			payload["businessName"] = reqBody.BusinessName
+		}
+	}
+
+	if reqBody.customerAddress != (customerAddress{}) {
+		if unverified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "addresses can only be changed on verified customers"})
+			return
+		}
+		if err := reqBody.customerAddress.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reqBody.customerAddress.payload(payload)
+	}
+
+	if len(payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("POST", cust.Href, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to update customer", "details": result})
+		return
+	}
+
+	updated, err := customerFromResult(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("Updated customer: %s\n", updated.Href)
+	c.JSON(http.StatusOK, updated)
+}
+
+// retryCustomerVerification resubmits KYC details for a personal customer in "retry" status
+// POST /api/dwolla/customer/:id/retry-verification
+func retryCustomerVerification(c *gin.Context) {
+	var reqBody struct {
+		FirstName   string       `json:"firstName" binding:"required"`
+		LastName    string       `json:"lastName" binding:"required"`
+		Email       string       `json:"email" binding:"required"`
+		DateOfBirth string       `json:"dateOfBirth"`
+		SSN         sensitiveSSN `json:"ssn"`
+		customerAddress
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, status, err := fetchCustomer(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if cust.Status != "retry" {
+		c.JSON(http.StatusConflict, gin.H{"error": "customer is not in retry status", "status": cust.Status})
+		return
+	}
+
+	if err := validatePersonalDetails(reqBody.customerAddress, reqBody.DateOfBirth, reqBody.SSN); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// A retry must include the full SSN
+	if len(reqBody.SSN.digits()) != 9 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retry verification requires the full 9 digit ssn"})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"firstName":   reqBody.FirstName,
+		"lastName":    reqBody.LastName,
+		"email":       reqBody.Email,
+		"type":        customerTypePersonal,
+		"dateOfBirth": reqBody.DateOfBirth,
+		"ssn":         reqBody.SSN.digits(),
+	}
+	reqBody.customerAddress.payload(payload)
+
+	result, status, err := makeDwollaRequest("POST", cust.Href, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to retry verification", "details": result})
+		return
+	}
+
+	updated, err := customerFromResult(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔁 Retried verification for customer %s: now %s\n", updated.Href, updated.Status)
+	c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDocumentSize is Dwolla's upload limit for verification documents
+const maxDocumentSize = 10 << 20 // 10 MB
+
+// Document types accepted by Dwolla
+var documentTypes = map[string]bool{
+	"passport": true,
+	"license":  true,
+	"idCard":   true,
+	"other":    true, // business documents only
+}
+
+// Content types accepted by Dwolla, keyed by detected MIME type
+var documentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// documentStatus tracks the verification document state reported by webhooks
+type documentStatus struct {
+	ResourceURL string `json:"resource_url"`
+	Topic       string `json:"topic"`
+	Status      string `json:"status"`
+	DocumentURL string `json:"document_url,omitempty"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+var (
+	// Latest document webhook status per customer or beneficial owner ID
+	documentStatuses = map[string]documentStatus{}
+	documentMutex    sync.RWMutex
+)
+
+// parseDocumentForm parses an upload request with its body capped just above the document limit.
+// It must run before any form field is read, or gin parses the whole body without the cap.
+func parseDocumentForm(c *gin.Context) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize+1<<20)
+	if err := c.Request.ParseMultipartForm(maxDocumentSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("file must be 10MB or smaller")
+		}
+		return fmt.Errorf("invalid multipart form: %w", err)
+	}
+	return nil
+}
+
+// readDocumentUpload reads and validates the multipart "file" field of a parsed upload request
+func readDocumentUpload(c *gin.Context) (string, []byte, error) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return "", nil, fmt.Errorf("file is required: %w", err)
+	}
+	if fileHeader.Size > maxDocumentSize {
+		return "", nil, fmt.Errorf("file must be 10MB or smaller")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxDocumentSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxDocumentSize {
+		return "", nil, fmt.Errorf("file must be 10MB or smaller")
+	}
+
+	// Check the actual content, not just the extension the client claims
+	contentType := http.DetectContentType(data)
+	if !documentContentTypes[contentType] {
+		return "", nil, fmt.Errorf("file must be a JPEG, PNG or PDF, got %s", contentType)
+	}
+
+	return filepath.Base(fileHeader.Filename), data, nil
+}
+
+// checkDocumentType checks a document type against its owner. customerType is the Dwolla
+// type of a customer owner and is only needed for 'other', which is for business customers.
+func checkDocumentType(documentType, kind, customerType string) error {
+	if !documentTypes[documentType] {
+		return fmt.Errorf("documentType must be 'passport', 'license', 'idCard' or 'other'")
+	}
+	if documentType == "other" && (kind != resourceCustomers || customerType != "business") {
+		return fmt.Errorf("documentType 'other' is only accepted for business customers")
+	}
+	return nil
+}
+
+// uploadDocument forwards a validated upload to a Dwolla documents collection
+func uploadDocument(c *gin.Context, kind string) {
+	ownerID, ownerURL, err := resolveResource(kind, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := parseDocumentForm(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	documentType := c.PostForm("documentType")
+	customerType := ""
+	if documentType == "other" && kind == resourceCustomers {
+		cust, status, err := fetchCustomer(ownerURL)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		customerType = cust.Type
+	}
+	if err := checkDocumentType(documentType, kind, customerType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName, data, err := readDocumentUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := ownerURL + "/documents"
+	fields := map[string]string{"documentType": documentType}
+	result, status, err := makeDwollaMultipartRequest(url, fields, fileName, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to upload document", "details": result})
+		return
+	}
+
+	documentURL := result["location"].(string)
+	fmt.Printf("📄 Uploaded %s document (%d bytes): %s\n", documentType, len(data), documentURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"owner_id":      ownerID,
+		"document_id":   resourceIDFromHref(documentURL),
+		"document_url":  documentURL,
+		"document_type": documentType,
+		"status":        "uploaded",
+	})
+}
+
+// listDocuments lists the documents of a customer or beneficial owner with their review status
+func listDocuments(c *gin.Context, kind string) {
+	ownerID, ownerURL, err := resolveResource(kind, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := ownerURL + "/documents"
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list documents", "details": result})
+		return
+	}
+
+	documents := []gin.H{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawDocuments, _ := embedded["documents"].([]interface{})
+	for _, raw := range rawDocuments {
+		document, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		documents = append(documents, gin.H{
+			"id":                document["id"],
+			"type":              document["type"],
+			"status":            document["status"], // "pending" or "reviewed"
+			"created":           document["created"],
+			"failureReason":     document["failureReason"],
+			"allFailureReasons": document["allFailureReasons"],
+		})
+	}
+
+	documentMutex.RLock()
+	webhookStatus, hasWebhookStatus := documentStatuses[ownerID]
+	documentMutex.RUnlock()
+
+	response := gin.H{
+		"owner_id":  ownerID,
+		"documents": documents,
+		"total":     len(documents),
+	}
+	if hasWebhookStatus {
+		response["verification_document"] = webhookStatus
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// uploadCustomerDocument uploads a verification document for a customer
+// POST /api/dwolla/customer/:id/documents (multipart: file, documentType)
+func uploadCustomerDocument(c *gin.Context) {
+	uploadDocument(c, resourceCustomers)
+}
+
+// listCustomerDocuments lists a customer's verification documents
+// GET /api/dwolla/customer/:id/documents
+func listCustomerDocuments(c *gin.Context) {
+	listDocuments(c, resourceCustomers)
+}
+
+// uploadOwnerDocument uploads a verification document for a beneficial owner
+// POST /api/dwolla/beneficial-owner/:id/documents (multipart: file, documentType)
+func uploadOwnerDocument(c *gin.Context) {
+	uploadDocument(c, resourceOwners)
+}
+
+// listOwnerDocuments lists a beneficial owner's verification documents
+// GET /api/dwolla/beneficial-owner/:id/documents
+func listOwnerDocuments(c *gin.Context) {
+	listDocuments(c, resourceOwners)
+}
+
+// handleDocumentWebhook records verification document webhooks for customers and beneficial owners.
+// It returns false when the topic is not a document event.
+func handleDocumentWebhook(topic string, webhook map[string]interface{}) bool {
+	var status string
+	switch {
+	case strings.HasSuffix(topic, "verification_document_needed"):
+		status = "needed"
+	case strings.HasSuffix(topic, "verification_document_uploaded"):
+		status = "uploaded"
+	case strings.HasSuffix(topic, "verification_document_failed"):
+		status = "failed"
+	case strings.HasSuffix(topic, "verification_document_approved"):
+		status = "approved"
+	default:
+		return false
+	}
+
+	links, _ := webhook["_links"].(map[string]interface{})
+	resourceHref := linkHref(links, "resource")
+
+	// "needed" events point at the customer or owner itself; the others point at the document
+	ownerHref := resourceHref
+	documentHref := ""
+	if status != "needed" {
+		documentHref = resourceHref
+		ownerHref = linkHref(links, "customer")
+		if strings.HasPrefix(topic, "customer_beneficial_owner_") {
+			if owner := linkHref(links, "beneficial-owner"); owner != "" {
+				ownerHref = owner
+			}
+		}
+	}
+	if ownerHref == "" {
+		return true
+	}
+
+	documentMutex.Lock()
+	documentStatuses[resourceIDFromHref(ownerHref)] = documentStatus{
+		ResourceURL: ownerHref,
+		Topic:       topic,
+		Status:      status,
+		DocumentURL: documentHref,
+		UpdatedAt:   time.Now().Format(time.RFC3339),
+	}
+	documentMutex.Unlock()
+
+	switch status {
+	case "needed":
+		fmt.Printf("📄 Verification document needed for %s\n", ownerHref)
+	case "uploaded":
+		fmt.Println("📄 Verification document uploaded, awaiting review")
+	case "failed":
+		fmt.Printf("❌ Verification document rejected, upload a new one for %s\n", ownerHref)
+	case "approved":
+		fmt.Println("✅ Verification document approved")
+	}
+
+	return true
+}
+
+// linkHref returns the href of a named HAL link, or "" if it is missing
+func linkHref(links map[string]interface{}, name string) string {
+	link, ok := links[name].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	href, _ := link["href"].(string)
+	return href
+}
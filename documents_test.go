@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestCheckDocumentType(t *testing.T) {
+	tests := []struct {
+		name         string
+		documentType string
+		kind         string
+		customerType string
+		wantErr      bool
+	}{
+		{"passport for personal customer", "passport", resourceCustomers, "", false},
+		{"license for beneficial owner", "license", resourceOwners, "", false},
+		{"idCard for business customer", "idCard", resourceCustomers, "", false},
+		{"other for business customer", "other", resourceCustomers, "business", false},
+		{"other for personal customer", "other", resourceCustomers, "personal", true},
+		{"other for receive-only customer", "other", resourceCustomers, "receive-only", true},
+		{"other for beneficial owner", "other", resourceOwners, "", true},
+		{"unknown type", "selfie", resourceCustomers, "business", true},
+		{"missing type", "", resourceCustomers, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDocumentType(tt.documentType, tt.kind, tt.customerType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkDocumentType(%q, %q, %q) error = %v, want error %v", tt.documentType, tt.kind, tt.customerType, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createDwollaExchange creates an exchange for a customer from an exchange partner token
+func createDwollaExchange(customerURL, partnerRef, token string) (string, int, error) {
+	_, partnerURL, err := resolveResource(resourcePartners, partnerRef)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+
+	payload := map[string]interface{}{
+		"_links": map[string]interface{}{
+			"exchange-partner": map[string]string{
+				"href": partnerURL,
+			},
+		},
+		"token": token,
+	}
+
+	result, status, err := makeDwollaRequest("POST", customerURL+"/exchanges", payload)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	if status != http.StatusCreated {
+		return "", status, fmt.Errorf("failed to create exchange: %v", result)
+	}
+
+	exchangeURL := result["location"].(string)
+	fmt.Printf("🔗 Created exchange: %s\n", exchangeURL)
+	return exchangeURL, http.StatusOK, nil
+}
+
+// exchangeFundingSourcePayload builds a funding source payload from a Dwolla exchange,
+// creating the exchange first when a partner token is given instead of an exchange ID
+func exchangeFundingSourcePayload(customerURL string, req fundingSourceRequest) (map[string]interface{}, int, error) {
+	bankAccountType := req.BankAccountType
+	if bankAccountType == "" {
+		bankAccountType = "checking"
+	}
+	if bankAccountType != "checking" && bankAccountType != "savings" {
+		return nil, http.StatusBadRequest, fmt.Errorf("bankAccountType must be 'checking' or 'savings'")
+	}
+
+	var exchangeURL string
+	switch {
+	case req.ExchangeID != "":
+		_, href, err := resolveResource(resourceExchanges, req.ExchangeID)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		exchangeURL = href
+	case req.ExchangePartnerID != "" && req.ExchangeToken != "":
+		href, status, err := createDwollaExchange(customerURL, req.ExchangePartnerID, req.ExchangeToken)
+		if err != nil {
+			return nil, status, err
+		}
+		exchangeURL = href
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("exchange_id or exchange_partner_id with exchange_token is required")
+	}
+
+	return map[string]interface{}{
+		"_links": map[string]interface{}{
+			"exchange": map[string]string{
+				"href": exchangeURL,
+			},
+		},
+		"bankAccountType": bankAccountType,
+	}, http.StatusOK, nil
+}
+
+// listExchangePartners lists the open-banking partners enabled for this Dwolla account
+// GET /api/dwolla/exchange-partners
+func listExchangePartners(c *gin.Context) {
+	url := DWOLLA_BASE_URL + "/exchange-partners"
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list exchange partners", "details": result})
+		return
+	}
+
+	partners := []gin.H{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawPartners, _ := embedded["exchange-partners"].([]interface{})
+	for _, raw := range rawPartners {
+		partner, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		partners = append(partners, gin.H{
+			"id":      partner["id"],
+			"name":    partner["name"],
+			"status":  partner["status"],
+			"created": partner["created"],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exchange_partners": partners,
+		"total":             len(partners),
+	})
+}
+
+// createExchange creates an exchange for a customer from a partner token
+// POST /api/dwolla/customer/:id/exchanges
+func createExchange(c *gin.Context) {
+	var reqBody struct {
+		ExchangePartnerID string `json:"exchange_partner_id" binding:"required"`
+		Token             string `json:"token" binding:"required"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exchangeURL, status, err := createDwollaExchange(customerURL, reqBody.ExchangePartnerID, reqBody.Token)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  customerID,
+		"exchange_id":  resourceIDFromHref(exchangeURL),
+		"exchange_url": exchangeURL,
+		"status":       "created",
+	})
+}
+
+// listExchanges lists a customer's exchanges
+// GET /api/dwolla/customer/:id/exchanges
+func listExchanges(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("GET", customerURL+"/exchanges", nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list exchanges", "details": result})
+		return
+	}
+
+	exchanges := []gin.H{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawExchanges, _ := embedded["exchanges"].([]interface{})
+	for _, raw := range rawExchanges {
+		exchange, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		links, _ := exchange["_links"].(map[string]interface{})
+		exchanges = append(exchanges, gin.H{
+			"id":                   exchange["id"],
+			"status":               exchange["status"],
+			"created":              exchange["created"],
+			"exchange_partner_url": linkHref(links, "exchange-partner"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id": customerID,
+		"exchanges":   exchanges,
+		"total":       len(exchanges),
+	})
+}
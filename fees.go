@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transferFeeRequest is a facilitator fee requested on transfer creation
+type transferFeeRequest struct {
+	Amount   float64 `json:"amount"`
+	ChargeTo string  `json:"charge_to"` // customer ID or URL, must own the source or destination
+}
+
+// transferFee is a facilitator fee recorded for revenue reporting
+type transferFee struct {
+	Amount      string `json:"amount"`
+	Currency    string `json:"currency"`
+	ChargeTo    string `json:"charge_to"`
+	ChargeToID  string `json:"charge_to_id"`
+	Facilitator string `json:"facilitator"` // always the master account
+}
+
+// amountToCents converts a dollar amount to whole cents, rounding half a cent away from zero.
+// Float noise is dropped first so 1.005 (100.49999... cents) rounds like the decimal it was written as.
+func amountToCents(amount float64) int64 {
+	cents := math.Round(amount*100*1e6) / 1e6
+	return int64(math.Round(cents))
+}
+
+// centsToAmount formats whole cents as a Dwolla amount value
+func centsToAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// parseAmountCents parses a Dwolla amount value such as "10.00" into cents
+func parseAmountCents(value string) (int64, error) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+	return amountToCents(amount), nil
+}
+
+// validateFees checks requested fees against the transfer amount in cents and the customers on either side
+func validateFees(fees []transferFeeRequest, amountCents int64, currency string, source, destination fundingSource) ([]transferFee, error) {
+	var validated []transferFee
+	var totalCents int64
+
+	for i, fee := range fees {
+		cents := amountToCents(fee.Amount)
+		if cents <= 0 {
+			return nil, fmt.Errorf("fees[%d].amount must be at least 0.01", i)
+		}
+
+		chargeToID, chargeToURL, err := resolveResource(resourceCustomers, fee.ChargeTo)
+		if err != nil {
+			return nil, fmt.Errorf("fees[%d].charge_to: %w", i, err)
+		}
+		// Dwolla only allows charging a customer that is party to the transfer
+		if chargeToURL != source.CustomerURL && chargeToURL != destination.CustomerURL {
+			return nil, fmt.Errorf("fees[%d].charge_to must be the source or destination customer", i)
+		}
+
+		totalCents += cents
+		validated = append(validated, transferFee{
+			Amount:      centsToAmount(cents),
+			Currency:    currency,
+			ChargeTo:    chargeToURL,
+			ChargeToID:  chargeToID,
+			Facilitator: "master",
+		})
+	}
+
+	if totalCents >= amountCents {
+		return nil, fmt.Errorf("total fees (%s) must be less than the transfer amount (%s)", centsToAmount(totalCents), centsToAmount(amountCents))
+	}
+	return validated, nil
+}
+
+// applyFees adds facilitator fees to a Dwolla transfer payload
+func applyFees(payload map[string]interface{}, fees []transferFee) {
+	if len(fees) == 0 {
+		return
+	}
+
+	dwollaFees := []map[string]interface{}{}
+	for _, fee := range fees {
+		dwollaFees = append(dwollaFees, map[string]interface{}{
+			"_links": map[string]interface{}{
+				"charge-to": map[string]string{
+					"href": fee.ChargeTo,
+				},
+			},
+			"amount": map[string]string{
+				"value":    fee.Amount,
+				"currency": fee.Currency,
+			},
+		})
+	}
+	payload["fees"] = dwollaFees
+}
+
+// fetchTransferFees retrieves the fees Dwolla charged on a transfer
+func fetchTransferFees(transferURL string) ([]gin.H, error) {
+	result, status, err := makeDwollaRequest("GET", transferURL+"/fees", nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to get transfer fees: %v", result)
+	}
+
+	fees := []gin.H{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawFees, _ := embedded["fees"].([]interface{})
+	for _, raw := range rawFees {
+		fee, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		links, _ := fee["_links"].(map[string]interface{})
+		fees = append(fees, gin.H{
+			"id":         fee["id"],
+			"status":     fee["status"],
+			"amount":     fee["amount"],
+			"created":    fee["created"],
+			"charged_to": linkHref(links, "charged-to"),
+		})
+	}
+	return fees, nil
+}
+
+// getFeeReport summarizes facilitator fee revenue from transfers created through this service
+// GET /api/dwolla/fees/report?startDate=2024-01-01&endDate=2024-01-31
+func getFeeReport(c *gin.Context) {
+	var start, end time.Time
+	if value := c.Query("startDate"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "startDate must be formatted as YYYY-MM-DD"})
+			return
+		}
+		start = parsed
+	}
+	if value := c.Query("endDate"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "endDate must be formatted as YYYY-MM-DD"})
+			return
+		}
+		end = parsed.AddDate(0, 0, 1)
+	}
+
+	earned := map[string]int64{}  // processed transfers, by currency
+	pending := map[string]int64{} // pending transfers, by currency
+	byCustomer := map[string]int64{}
+	feeCount := 0
+
+	transferMutex.RLock()
+	for _, record := range transferRecords {
+		created, _ := time.Parse(time.RFC3339, record.CreatedAt)
+		if (!start.IsZero() && created.Before(start)) || (!end.IsZero() && !created.Before(end)) {
+			continue
+		}
+		for _, fee := range record.Fees {
+			cents, err := parseAmountCents(fee.Amount)
+			if err != nil {
+				continue
+			}
+			switch record.Status {
+			case transferStatusProcessed:
+				earned[fee.Currency] += cents
+				byCustomer[fee.ChargeToID] += cents
+			case transferStatusPending:
+				pending[fee.Currency] += cents
+			default:
+				// Failed and cancelled transfers do not collect fees
+				continue
+			}
+			feeCount++
+		}
+	}
+	transferMutex.RUnlock()
+
+	format := func(totals map[string]int64) gin.H {
+		formatted := gin.H{}
+		for key, cents := range totals {
+			formatted[key] = centsToAmount(cents)
+		}
+		return formatted
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date":  c.Query("startDate"),
+		"end_date":    c.Query("endDate"),
+		"earned":      format(earned),
+		"pending":     format(pending),
+		"by_customer": format(byCustomer),
+		"fee_count":   feeCount,
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAmountToCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   int64
+	}{
+		{0, 0},
+		{0.01, 1},
+		{0.1 + 0.2, 30}, // 0.30000000000000004
+		{1.005, 101},    // 100.49999999999999 before rounding half away from zero
+		{19.99, 1999},
+		{1234.565, 123457},
+		{0.125, 13},
+		{2.675, 268}, // "%.2f" formats it as 2.67
+		{-2.5, -250},
+	}
+
+	for _, tt := range tests {
+		if got := amountToCents(tt.amount); got != tt.want {
+			t.Errorf("amountToCents(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestCentsToAmount(t *testing.T) {
+	tests := []struct {
+		cents int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{100, "1.00"},
+		{123456, "1234.56"},
+		{-5, "-0.05"},
+		{-1999, "-19.99"},
+	}
+
+	for _, tt := range tests {
+		if got := centsToAmount(tt.cents); got != tt.want {
+			t.Errorf("centsToAmount(%d) = %q, want %q", tt.cents, got, tt.want)
+		}
+	}
+}
+
+func TestParseAmountCents(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"10.00", 1000, false},
+		{"0.29", 29, false},
+		{"5", 500, false},
+		{"", 0, true},
+		{"ten", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAmountCents(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAmountCents(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateFees(t *testing.T) {
+	const (
+		payer = "11111111-1111-1111-1111-111111111111"
+		payee = "22222222-2222-2222-2222-222222222222"
+		other = "33333333-3333-3333-3333-333333333333"
+	)
+	source := fundingSource{CustomerURL: resourceHref(resourceCustomers, payer)}
+	destination := fundingSource{CustomerURL: resourceHref(resourceCustomers, payee)}
+
+	tests := []struct {
+		name      string
+		fees      []transferFeeRequest
+		amount    float64
+		wantErr   string
+		wantTotal []string
+	}{
+		{"no fees", nil, 10, "", nil},
+		{"payer fee", []transferFeeRequest{{Amount: 0.5, ChargeTo: payer}}, 10, "", []string{"0.50"}},
+		{"payee fee by URL", []transferFeeRequest{{Amount: 1.25, ChargeTo: resourceHref(resourceCustomers, payee)}}, 10, "", []string{"1.25"}},
+		{"fee rounded to cents", []transferFeeRequest{{Amount: 0.105, ChargeTo: payer}}, 10, "", []string{"0.11"}},
+		{"both sides", []transferFeeRequest{{Amount: 1, ChargeTo: payer}, {Amount: 2, ChargeTo: payee}}, 10, "", []string{"1.00", "2.00"}},
+		{"zero fee", []transferFeeRequest{{Amount: 0, ChargeTo: payer}}, 10, "at least 0.01", nil},
+		{"sub-cent fee", []transferFeeRequest{{Amount: 0.004, ChargeTo: payer}}, 10, "at least 0.01", nil},
+		{"not a party", []transferFeeRequest{{Amount: 1, ChargeTo: other}}, 10, "source or destination customer", nil},
+		{"bad customer", []transferFeeRequest{{Amount: 1, ChargeTo: "nobody"}}, 10, "charge_to", nil},
+		{"equals amount", []transferFeeRequest{{Amount: 10, ChargeTo: payer}}, 10, "less than the transfer amount", nil},
+		{"total exceeds amount", []transferFeeRequest{{Amount: 6, ChargeTo: payer}, {Amount: 4.01, ChargeTo: payee}}, 10, "less than the transfer amount", nil},
+		// The amount is checked as the cents sent to Dwolla: 0.125 is sent as 0.13
+		{"under half-cent amount", []transferFeeRequest{{Amount: 0.12, ChargeTo: payer}}, 0.125, "", []string{"0.12"}},
+		{"equals half-cent amount", []transferFeeRequest{{Amount: 0.13, ChargeTo: payer}}, 0.125, "(0.13)", nil},
+	}
+
+	for _, tt := range tests {
+		fees, err := validateFees(tt.fees, amountToCents(tt.amount), "USD", source, destination)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if len(fees) != len(tt.wantTotal) {
+			t.Errorf("%s: got %d fees, want %d", tt.name, len(fees), len(tt.wantTotal))
+			continue
+		}
+		for i, fee := range fees {
+			if fee.Amount != tt.wantTotal[i] || fee.Currency != "USD" || fee.Facilitator != "master" {
+				t.Errorf("%s: fee %d = %+v, want amount %s", tt.name, i, fee, tt.wantTotal[i])
+			}
+		}
+	}
+}
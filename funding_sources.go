@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fundingSource is the typed view of a Dwolla funding source resource
+type fundingSource struct {
+	ID              string   `json:"id"`
+	Href            string   `json:"href"`
+	Status          string   `json:"status"` // "unverified" or "verified"
+	Type            string   `json:"type"`   // "bank" or "balance"
+	BankAccountType string   `json:"bankAccountType,omitempty"`
+	Name            string   `json:"name"`
+	BankName        string   `json:"bankName,omitempty"`
+	Created         string   `json:"created"`
+	Removed         bool     `json:"removed"`
+	Channels        []string `json:"channels,omitempty"`
+	Fingerprint     string   `json:"fingerprint,omitempty"`
+	CustomerURL     string   `json:"customerUrl,omitempty"`
+	AccountURL      string   `json:"accountUrl,omitempty"`
+}
+
+// fundingSourceFromResult converts a Dwolla funding source response into a fundingSource
+func fundingSourceFromResult(result map[string]interface{}) (fundingSource, error) {
+	var fs fundingSource
+	if err := decodeDwollaResource(result, &fs); err != nil {
+		return fundingSource{}, err
+	}
+
+	links, _ := result["_links"].(map[string]interface{})
+	fs.Href = linkHref(links, "self")
+	if fs.Href == "" && fs.ID != "" {
+		fs.Href = resourceHref(resourceFundingSources, fs.ID)
+	}
+	fs.CustomerURL = linkHref(links, "customer")
+	fs.AccountURL = linkHref(links, "account")
+	return fs, nil
+}
+
+// fetchFundingSource retrieves a funding source by ID or URL
+func fetchFundingSource(ref string) (fundingSource, int, error) {
+	_, fundingSourceURL, err := resolveResource(resourceFundingSources, ref)
+	if err != nil {
+		return fundingSource{}, http.StatusBadRequest, err
+	}
+
+	result, status, err := makeDwollaRequest("GET", fundingSourceURL, nil)
+	if err != nil {
+		return fundingSource{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return fundingSource{}, status, fmt.Errorf("failed to get funding source: %v", result)
+	}
+
+	fs, err := fundingSourceFromResult(result)
+	if err != nil {
+		return fundingSource{}, http.StatusInternalServerError, err
+	}
+	return fs, http.StatusOK, nil
+}
+
+// fundingSourceBalance is the balance of a balance-type funding source
+type fundingSourceBalance struct {
+	Balance     transferAmount  `json:"balance"`
+	Total       *transferAmount `json:"total,omitempty"`
+	LastUpdated string          `json:"last_updated"`
+}
+
+// fetchFundingSourceBalance retrieves the balance of a balance-type funding source
+func fetchFundingSourceBalance(fs fundingSource) (fundingSourceBalance, int, error) {
+	if fs.Type != "balance" {
+		return fundingSourceBalance{}, http.StatusBadRequest, fmt.Errorf("balance is only available for balance funding sources, not %s", fs.Type)
+	}
+
+	result, status, err := makeDwollaRequest("GET", fs.Href+"/balance", nil)
+	if err != nil {
+		return fundingSourceBalance{}, http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return fundingSourceBalance{}, status, fmt.Errorf("failed to get balance: %v", result)
+	}
+
+	var balance struct {
+		Balance     transferAmount  `json:"balance"`
+		Total       *transferAmount `json:"total"`
+		LastUpdated string          `json:"lastUpdated"`
+	}
+	if err := decodeDwollaResource(result, &balance); err != nil {
+		return fundingSourceBalance{}, http.StatusInternalServerError, err
+	}
+	return fundingSourceBalance{
+		Balance:     balance.Balance,
+		Total:       balance.Total,
+		LastUpdated: balance.LastUpdated,
+	}, http.StatusOK, nil
+}
+
+// embeddedFundingSources converts the _embedded funding-sources list of a Dwolla response
+func embeddedFundingSources(result map[string]interface{}) []fundingSource {
+	fundingSources := []fundingSource{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawFundingSources, _ := embedded["funding-sources"].([]interface{})
+	for _, raw := range rawFundingSources {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fs, err := fundingSourceFromResult(item)
+		if err != nil {
+			continue
+		}
+		fundingSources = append(fundingSources, fs)
+	}
+	return fundingSources
+}
+
+// fundingSourceRequest is the body of POST /api/dwolla/funding-source.
+// Provider-specific fields are only read by the selected bank-linking provider.
+type fundingSourceRequest struct {
+	CustomerURL string `json:"customer_url"` // customer ID or URL
+	CustomerID  string `json:"customer_id"`
+	Name        string `json:"name"`
+	Provider    string `json:"provider"` // "plaid" or "exchange", defaults to BANK_LINK_PROVIDER
+
+	// Plaid
+	PlaidItemID    string `json:"plaid_item_id"`    // linked through Plaid Link
+	PlaidAccountID string `json:"plaid_account_id"` // account chosen from the item
+	PlaidSandbox   bool   `json:"plaid_sandbox"`    // use the Plaid service's sandbox processor token instead (sandbox only)
+
+	// Dwolla open-banking exchange
+	ExchangeID        string `json:"exchange_id"`
+	ExchangePartnerID string `json:"exchange_partner_id"`
+	ExchangeToken     string `json:"exchange_token"`
+	BankAccountType   string `json:"bankAccountType"`
+}
+
+// bankLinkProvider builds the provider-specific part of a Dwolla funding source payload
+type bankLinkProvider func(customerURL string, req fundingSourceRequest) (map[string]interface{}, int, error)
+
+// Bank-linking providers selectable per request
+var bankLinkProviders = map[string]bankLinkProvider{
+	"plaid":    plaidFundingSourcePayload,
+	"exchange": exchangeFundingSourcePayload,
+}
+
+// createFundingSource adds a bank account as a funding source
+// POST /api/dwolla/funding-source
+func createFundingSource(c *gin.Context) {
+	var reqBody fundingSourceRequest
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerRef := reqBody.CustomerURL
+	if customerRef == "" {
+		customerRef = reqBody.CustomerID
+	}
+	_, customerURL, err := resolveResource(resourceCustomers, customerRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider := reqBody.Provider
+	if provider == "" {
+		provider = BANK_LINK_PROVIDER
+	}
+	buildPayload, ok := bankLinkProviders[provider]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider must be 'plaid' or 'exchange'"})
+		return
+	}
+
+	payload, status, err := buildPayload(customerURL, reqBody)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Set default name if not provided
+	name := reqBody.Name
+	if name == "" {
+		name = "Bank Account"
+	}
+	payload["name"] = name
+
+	url := customerURL + "/funding-sources"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to create funding source", "details": result})
+		return
+	}
+
+	fundingSourceURL := result["location"].(string)
+	fmt.Printf("Created funding source via %s: %s\n", provider, fundingSourceURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding_source_id":  resourceIDFromHref(fundingSourceURL),
+		"funding_source_url": fundingSourceURL,
+		"provider":           provider,
+		"status":             "created",
+	})
+}
+
+// listCustomerFundingSources lists a customer's funding sources
+// GET /api/dwolla/customer/:id/funding-sources?removed=false
+func listCustomerFundingSources(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url := customerURL + "/funding-sources"
+	if removed := c.Query("removed"); removed != "" {
+		if removed != "true" && removed != "false" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "removed must be 'true' or 'false'"})
+			return
+		}
+		url += "?removed=" + removed
+	}
+
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list funding sources", "details": result})
+		return
+	}
+
+	fundingSources := embeddedFundingSources(result)
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":     customerID,
+		"funding_sources": fundingSources,
+		"total":           len(fundingSources),
+	})
+}
+
+// getFundingSource retrieves a single funding source with its status and bank name
+// GET /api/dwolla/funding-source/:id
+func getFundingSource(c *gin.Context) {
+	fs, status, err := fetchFundingSource(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, fs)
+}
+
+// getFundingSourceBalance returns the balance of a balance-type funding source
+// GET /api/dwolla/funding-source/:id/balance
+func getFundingSourceBalance(c *gin.Context) {
+	fs, status, err := fetchFundingSource(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if fs.Type != "balance" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "balance is only available for balance funding sources", "type": fs.Type})
+		return
+	}
+
+	balance, status, err := fetchFundingSourceBalance(fs)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding_source_id": fs.ID,
+		"balance":           balance.Balance,
+		"total":             balance.Total,
+		"last_updated":      balance.LastUpdated,
+	})
+}
+
+// updateFundingSource renames a funding source
+// POST /api/dwolla/funding-source/:id
+func updateFundingSource(c *gin.Context) {
+	var reqBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(reqBody.Name)
+	if name == "" || len(name) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be between 1 and 50 characters"})
+		return
+	}
+
+	_, fundingSourceURL, err := resolveResource(resourceFundingSources, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"name": name,
+	}
+
+	result, status, err := makeDwollaRequest("POST", fundingSourceURL, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to update funding source", "details": result})
+		return
+	}
+
+	fs, err := fundingSourceFromResult(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("Renamed funding source %s to %q\n", fs.Href, fs.Name)
+	c.JSON(http.StatusOK, fs)
+}
+
+// removeFundingSource soft-removes a funding source; Dwolla keeps it for history
+// DELETE /api/dwolla/funding-source/:id
+func removeFundingSource(c *gin.Context) {
+	fundingSourceID, fundingSourceURL, err := resolveResource(resourceFundingSources, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"removed": true,
+	}
+
+	result, status, err := makeDwollaRequest("POST", fundingSourceURL, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to remove funding source", "details": result})
+		return
+	}
+
+	fmt.Printf("Removed funding source: %s\n", fundingSourceURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding_source_id":  fundingSourceID,
+		"funding_source_url": fundingSourceURL,
+		"status":             "removed",
+	})
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ledger accounts. Each customer has its own account, money moved for the master account is
+// booked to the platform account, and transfers that have not settled sit in transit.
+const (
+	ledgerAccountPlatform  = "platform"
+	ledgerAccountInTransit = "in_transit"
+	ledgerCustomerPrefix   = "customer:"
+)
+
+// Mass payment items are booked as ledger transfers named mass-payment:<mass payment ID>:<row>
+const ledgerMassPaymentPrefix = "mass-payment:"
+
+// Ledger states of a transfer
+const (
+	ledgerStatePending  = "pending"  // source debited into transit
+	ledgerStatePosted   = "posted"   // moved from transit to the destination
+	ledgerStateReversed = "reversed" // returned to the source
+)
+
+// ledgerLine debits or credits one account. Amounts are whole cents and exactly one side is set.
+type ledgerLine struct {
+	Account string `json:"account"`
+	Debit   int64  `json:"debit"`
+	Credit  int64  `json:"credit"`
+}
+
+// ledgerEntry is one balanced journal entry
+type ledgerEntry struct {
+	ID          int          `json:"id"`
+	TransferID  string       `json:"transfer_id"`
+	Type        string       `json:"type"` // "pending", "post", "fee" or "reverse"
+	Description string       `json:"description"`
+	Currency    string       `json:"currency"`
+	Lines       []ledgerLine `json:"lines"`
+	Source      string       `json:"source"` // "api" or the webhook topic
+	EventID     string       `json:"event_id,omitempty"`
+	CreatedAt   string       `json:"created_at"`
+}
+
+// ledgerTransfer is what the ledger knows about a transfer created through this service
+type ledgerTransfer struct {
+	TransferID         string        `json:"transfer_id"`
+	DwollaTransferID   string        `json:"dwolla_transfer_id,omitempty"` // the transfer of a mass payment item
+	SourceAccount      string        `json:"source_account"`
+	DestinationAccount string        `json:"destination_account"`
+	Amount             int64         `json:"amount"`
+	Currency           string        `json:"currency"`
+	Fees               []transferFee `json:"fees,omitempty"`
+	State              string        `json:"state"`
+}
+
+// ledgerAccountBalance is the running total of one account
+type ledgerAccountBalance struct {
+	Account string `json:"account"`
+	Debits  int64  `json:"debits"`
+	Credits int64  `json:"credits"`
+}
+
+// balance is credits minus debits: money received less money sent
+func (b ledgerAccountBalance) balance() int64 {
+	return b.Credits - b.Debits
+}
+
+// view formats an account balance in dollars for responses
+func (b ledgerAccountBalance) view() gin.H {
+	return gin.H{
+		"account": b.Account,
+		"debits":  centsToAmount(b.Debits),
+		"credits": centsToAmount(b.Credits),
+		"balance": centsToAmount(b.balance()),
+	}
+}
+
+var (
+	// The journal, account totals and transfer states, and the Dwolla transfers of mass payment
+	// items mapped to the ledger transfers they are booked under. ledgerMutex guards all four.
+	ledgerEntries         []ledgerEntry
+	ledgerAccounts        = map[string]*ledgerAccountBalance{}
+	ledgerTransfers       = map[string]*ledgerTransfer{}
+	ledgerTransferAliases = map[string]string{}
+	ledgerMutex           sync.RWMutex
+)
+
+// ledgerAccountFor names the ledger account of the owner of a funding source
+func ledgerAccountFor(fs fundingSource) string {
+	if fs.CustomerURL != "" {
+		return ledgerCustomerPrefix + resourceIDFromHref(fs.CustomerURL)
+	}
+	return ledgerAccountPlatform
+}
+
+// checkLedgerEntry checks that every line has exactly one positive side and that the
+// entry's debits equal its credits
+func checkLedgerEntry(entry ledgerEntry) error {
+	if len(entry.Lines) == 0 {
+		return fmt.Errorf("ledger entry for %s has no lines", entry.TransferID)
+	}
+	var debits, credits int64
+	for _, line := range entry.Lines {
+		if line.Account == "" {
+			return fmt.Errorf("ledger line for %s has no account", entry.TransferID)
+		}
+		if line.Debit < 0 || line.Credit < 0 || (line.Debit == 0) == (line.Credit == 0) {
+			return fmt.Errorf("ledger line for %s must have exactly one positive side", line.Account)
+		}
+		debits += line.Debit
+		credits += line.Credit
+	}
+	if debits != credits {
+		return fmt.Errorf("unbalanced ledger entry: debits %s, credits %s", centsToAmount(debits), centsToAmount(credits))
+	}
+	return nil
+}
+
+// postLedgerEntries appends journal entries all or nothing: every entry is checked before
+// any is posted. The caller must hold ledgerMutex.
+func postLedgerEntries(entries []ledgerEntry) error {
+	for _, entry := range entries {
+		if err := checkLedgerEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for _, entry := range entries {
+		entry.ID = len(ledgerEntries) + 1
+		if entry.CreatedAt == "" {
+			entry.CreatedAt = now
+		}
+		for _, line := range entry.Lines {
+			account, ok := ledgerAccounts[line.Account]
+			if !ok {
+				account = &ledgerAccountBalance{Account: line.Account}
+				ledgerAccounts[line.Account] = account
+			}
+			account.Debits += line.Debit
+			account.Credits += line.Credit
+		}
+		ledgerEntries = append(ledgerEntries, entry)
+	}
+	return nil
+}
+
+// transferLedgerLines moves an amount from one account to another
+func transferLedgerLines(from, to string, cents int64) []ledgerLine {
+	return []ledgerLine{
+		{Account: from, Debit: cents},
+		{Account: to, Credit: cents},
+	}
+}
+
+// recordLedgerPending books a newly created transfer: the source is debited into transit
+func recordLedgerPending(record transferRecord, source, destination fundingSource) {
+	cents, err := parseAmountCents(record.Amount)
+	if err != nil {
+		log.Printf("❌ Ledger: invalid amount %q for transfer %s\n", record.Amount, record.ID)
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	if _, ok := ledgerTransfers[record.ID]; ok {
+		return
+	}
+
+	lt := &ledgerTransfer{
+		TransferID:         record.ID,
+		SourceAccount:      ledgerAccountFor(source),
+		DestinationAccount: ledgerAccountFor(destination),
+		Amount:             cents,
+		Currency:           record.Currency,
+		Fees:               record.Fees,
+		State:              ledgerStatePending,
+	}
+	err = postLedgerEntries([]ledgerEntry{{
+		TransferID:  record.ID,
+		Type:        "pending",
+		Description: fmt.Sprintf("Transfer %s from %s to %s created", record.ID, lt.SourceAccount, lt.DestinationAccount),
+		Currency:    record.Currency,
+		Lines:       transferLedgerLines(lt.SourceAccount, ledgerAccountInTransit, cents),
+		Source:      "api",
+	}})
+	if err != nil {
+		log.Printf("❌ Ledger: %v\n", err)
+		return
+	}
+	ledgerTransfers[record.ID] = lt
+}
+
+// massPaymentLedgerID names the ledger transfer of one mass payment item
+func massPaymentLedgerID(massPaymentID string, row int) string {
+	return fmt.Sprintf("%s%s:%d", ledgerMassPaymentPrefix, massPaymentID, row)
+}
+
+// recordLedgerMassPayment books every item of a new mass payment like a transfer: the source
+// is debited into transit once per item. The items are booked together or not at all.
+func recordLedgerMassPayment(record massPaymentRecord, source fundingSource, destinations map[string]fundingSource) {
+	sourceAccount := ledgerAccountFor(source)
+	var entries []ledgerEntry
+	var transfers []*ledgerTransfer
+	for _, item := range record.Items {
+		cents, err := parseAmountCents(item.Amount)
+		if err != nil {
+			log.Printf("❌ Ledger: invalid amount %q for mass payment %s row %d\n", item.Amount, record.ID, item.Row)
+			return
+		}
+		lt := &ledgerTransfer{
+			TransferID:         massPaymentLedgerID(record.ID, item.Row),
+			SourceAccount:      sourceAccount,
+			DestinationAccount: ledgerAccountFor(destinations[item.DestinationURL]),
+			Amount:             cents,
+			Currency:           record.Currency,
+			State:              ledgerStatePending,
+		}
+		transfers = append(transfers, lt)
+		entries = append(entries, ledgerEntry{
+			TransferID:  lt.TransferID,
+			Type:        "pending",
+			Description: fmt.Sprintf("Mass payment %s row %d from %s to %s created", record.ID, item.Row, lt.SourceAccount, lt.DestinationAccount),
+			Currency:    record.Currency,
+			Lines:       transferLedgerLines(lt.SourceAccount, ledgerAccountInTransit, cents),
+			Source:      "api",
+		})
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	for _, lt := range transfers {
+		if _, ok := ledgerTransfers[lt.TransferID]; ok {
+			return
+		}
+	}
+	if err := postLedgerEntries(entries); err != nil {
+		log.Printf("❌ Ledger: mass payment %s not booked: %v\n", record.ID, err)
+		return
+	}
+	for _, lt := range transfers {
+		ledgerTransfers[lt.TransferID] = lt
+	}
+}
+
+// linkLedgerMassPaymentItem books later status changes of the transfer Dwolla created for a
+// mass payment item under the item, so transfer webhooks and lookups post or reverse it
+func linkLedgerMassPaymentItem(massPaymentID string, row int, transferID string) {
+	if transferID == "" {
+		return
+	}
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	lt, ok := ledgerTransfers[massPaymentLedgerID(massPaymentID, row)]
+	if !ok {
+		return
+	}
+	lt.DwollaTransferID = transferID
+	ledgerTransferAliases[transferID] = lt.TransferID
+}
+
+// ledgerTransferID returns the ledger transfer a Dwolla transfer is booked under.
+// The caller must hold ledgerMutex.
+func ledgerTransferID(transferID string) string {
+	if id, ok := ledgerTransferAliases[transferID]; ok {
+		return id
+	}
+	return transferID
+}
+
+// applyLedgerStatus posts a completed transfer to its destination, or reverses a failed or
+// cancelled one back to its source. Repeated or out-of-order statuses are ignored.
+func applyLedgerStatus(transferID, status, source, eventID string) {
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	lt, ok := ledgerTransfers[ledgerTransferID(transferID)]
+	if !ok {
+		return
+	}
+	transferID = lt.TransferID
+
+	var entries []ledgerEntry
+	newState := lt.State
+	switch {
+	case status == transferStatusProcessed && lt.State == ledgerStatePending:
+		newState = ledgerStatePosted
+		entries = append(entries, ledgerEntry{
+			Type:        "post",
+			Description: fmt.Sprintf("Transfer %s completed", transferID),
+			Lines:       transferLedgerLines(ledgerAccountInTransit, lt.DestinationAccount, lt.Amount),
+		})
+		// Facilitator fees are deducted from the charged customer when the transfer settles
+		for _, fee := range lt.Fees {
+			cents, err := parseAmountCents(fee.Amount)
+			if err != nil {
+				log.Printf("❌ Ledger: invalid fee %q on transfer %s\n", fee.Amount, transferID)
+				return
+			}
+			entries = append(entries, ledgerEntry{
+				Type:        "fee",
+				Description: fmt.Sprintf("Facilitator fee on transfer %s", transferID),
+				Lines:       transferLedgerLines(ledgerCustomerPrefix+fee.ChargeToID, ledgerAccountPlatform, cents),
+			})
+		}
+
+	case (status == transferStatusFailed || status == transferStatusCancelled) && lt.State == ledgerStatePending:
+		newState = ledgerStateReversed
+		entries = append(entries, ledgerEntry{
+			Type:        "reverse",
+			Description: fmt.Sprintf("Transfer %s %s", transferID, status),
+			Lines:       transferLedgerLines(ledgerAccountInTransit, lt.SourceAccount, lt.Amount),
+		})
+
+	// A posted transfer can still be returned, which undoes the payment and its fees
+	case status == transferStatusFailed && lt.State == ledgerStatePosted:
+		newState = ledgerStateReversed
+		entries = append(entries, ledgerEntry{
+			Type:        "reverse",
+			Description: fmt.Sprintf("Transfer %s returned after completing", transferID),
+			Lines:       transferLedgerLines(lt.DestinationAccount, lt.SourceAccount, lt.Amount),
+		})
+		for _, fee := range lt.Fees {
+			cents, err := parseAmountCents(fee.Amount)
+			if err != nil {
+				log.Printf("❌ Ledger: invalid fee %q on transfer %s\n", fee.Amount, transferID)
+				return
+			}
+			entries = append(entries, ledgerEntry{
+				Type:        "reverse",
+				Description: fmt.Sprintf("Facilitator fee on transfer %s refunded", transferID),
+				Lines:       transferLedgerLines(ledgerAccountPlatform, ledgerCustomerPrefix+fee.ChargeToID, cents),
+			})
+		}
+
+	default:
+		return
+	}
+
+	// The entries of a status change are posted together or not at all
+	for i := range entries {
+		entries[i].TransferID = transferID
+		entries[i].Currency = lt.Currency
+		entries[i].Source = source
+		entries[i].EventID = eventID
+	}
+	if err := postLedgerEntries(entries); err != nil {
+		log.Printf("❌ Ledger: transfer %s stays %s: %v\n", transferID, lt.State, err)
+		return
+	}
+	lt.State = newState
+	fmt.Printf("📒 Ledger: transfer %s %s\n", transferID, newState)
+}
+
+// ledgerTotals sums every account. Debits always equal credits unless the ledger is corrupt.
+func ledgerTotals() (int64, int64) {
+	var debits, credits int64
+	for _, account := range ledgerAccounts {
+		debits += account.Debits
+		credits += account.Credits
+	}
+	return debits, credits
+}
+
+// ledgerAccountView returns the balance of one account and the transfers still in transit from it
+func ledgerAccountView(name string) gin.H {
+	ledgerMutex.RLock()
+	defer ledgerMutex.RUnlock()
+
+	account := ledgerAccountBalance{Account: name}
+	if a, ok := ledgerAccounts[name]; ok {
+		account = *a
+	}
+
+	var pendingOut, pendingIn int64
+	transfers := []ledgerTransfer{}
+	for _, lt := range ledgerTransfers {
+		if lt.SourceAccount != name && lt.DestinationAccount != name {
+			continue
+		}
+		transfers = append(transfers, *lt)
+		if lt.State != ledgerStatePending {
+			continue
+		}
+		if lt.SourceAccount == name {
+			pendingOut += lt.Amount
+		}
+		if lt.DestinationAccount == name {
+			pendingIn += lt.Amount
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].TransferID < transfers[j].TransferID
+	})
+
+	view := account.view()
+	view["pending_out"] = centsToAmount(pendingOut)
+	view["pending_in"] = centsToAmount(pendingIn)
+	view["transfers"] = transfers
+	return view
+}
+
+// getLedgerAccounts returns every ledger account with the trial balance totals
+// GET /api/dwolla/ledger/accounts
+func getLedgerAccounts(c *gin.Context) {
+	ledgerMutex.RLock()
+	names := make([]string, 0, len(ledgerAccounts))
+	for name := range ledgerAccounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	accounts := []gin.H{}
+	for _, name := range names {
+		accounts = append(accounts, ledgerAccounts[name].view())
+	}
+	debits, credits := ledgerTotals()
+
+	// Money in transit must be exactly the transfers that are still pending
+	var pending int64
+	for _, lt := range ledgerTransfers {
+		if lt.State == ledgerStatePending {
+			pending += lt.Amount
+		}
+	}
+	var inTransit int64
+	if account, ok := ledgerAccounts[ledgerAccountInTransit]; ok {
+		inTransit = account.balance()
+	}
+	ledgerMutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts":      accounts,
+		"total_debits":  centsToAmount(debits),
+		"total_credits": centsToAmount(credits),
+		"in_transit":    centsToAmount(inTransit),
+		"pending":       centsToAmount(pending),
+		"balanced":      debits == credits && inTransit == pending,
+	})
+}
+
+// getLedgerCustomer returns a customer's ledger balance
+// GET /api/dwolla/ledger/customer/:id
+func getLedgerCustomer(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view := ledgerAccountView(ledgerCustomerPrefix + customerID)
+	view["customer_id"] = customerID
+	view["customer_url"] = customerURL
+	c.JSON(http.StatusOK, view)
+}
+
+// getLedgerPlatform returns the platform (master account) ledger balance
+// GET /api/dwolla/ledger/platform
+func getLedgerPlatform(c *gin.Context) {
+	c.JSON(http.StatusOK, ledgerAccountView(ledgerAccountPlatform))
+}
+
+// listLedgerEntries returns the journal, optionally filtered by ?transfer_id=, ?mass_payment_id= or ?account=
+// GET /api/dwolla/ledger/entries
+func listLedgerEntries(c *gin.Context) {
+	transferID := c.Query("transfer_id")
+	if transferID != "" {
+		id, _, err := resolveResource(resourceTransfers, transferID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		transferID = id
+	}
+	massPaymentPrefix := ""
+	if massPaymentID := c.Query("mass_payment_id"); massPaymentID != "" {
+		massPaymentPrefix = ledgerMassPaymentPrefix + massPaymentID + ":"
+	}
+	account := c.Query("account")
+
+	ledgerMutex.RLock()
+	if transferID != "" {
+		transferID = ledgerTransferID(transferID)
+	}
+	entries := []ledgerEntry{}
+	for _, entry := range ledgerEntries {
+		if transferID != "" && entry.TransferID != transferID {
+			continue
+		}
+		if massPaymentPrefix != "" && !strings.HasPrefix(entry.TransferID, massPaymentPrefix) {
+			continue
+		}
+		if account != "" && !ledgerEntryTouches(entry, account) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	ledgerMutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(entries),
+		"entries": entries,
+	})
+}
+
+// ledgerEntryTouches reports whether a journal entry has a line for an account
+func ledgerEntryTouches(entry ledgerEntry, account string) bool {
+	for _, line := range entry.Lines {
+		if strings.EqualFold(line.Account, account) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// resetLedger empties the ledger for one test and restores it afterwards
+func resetLedger(t *testing.T) {
+	t.Helper()
+	ledgerMutex.Lock()
+	entries, accounts, transfers, aliases := ledgerEntries, ledgerAccounts, ledgerTransfers, ledgerTransferAliases
+	ledgerEntries = nil
+	ledgerAccounts = map[string]*ledgerAccountBalance{}
+	ledgerTransfers = map[string]*ledgerTransfer{}
+	ledgerTransferAliases = map[string]string{}
+	ledgerMutex.Unlock()
+	t.Cleanup(func() {
+		ledgerMutex.Lock()
+		ledgerEntries, ledgerAccounts, ledgerTransfers, ledgerTransferAliases = entries, accounts, transfers, aliases
+		ledgerMutex.Unlock()
+	})
+}
+
+// assertLedgerBalanced checks the trial balance and that in_transit holds exactly the pending transfers
+func assertLedgerBalanced(t *testing.T) {
+	t.Helper()
+	ledgerMutex.RLock()
+	defer ledgerMutex.RUnlock()
+
+	debits, credits := ledgerTotals()
+	if debits != credits {
+		t.Errorf("total debits %d != total credits %d", debits, credits)
+	}
+	var pending, inTransit int64
+	for _, lt := range ledgerTransfers {
+		if lt.State == ledgerStatePending {
+			pending += lt.Amount
+		}
+	}
+	if account, ok := ledgerAccounts[ledgerAccountInTransit]; ok {
+		inTransit = account.balance()
+	}
+	if inTransit != pending {
+		t.Errorf("in_transit %d != pending transfers %d", inTransit, pending)
+	}
+}
+
+// ledgerBalance returns the balance of one account in cents
+func ledgerBalance(account string) int64 {
+	ledgerMutex.RLock()
+	defer ledgerMutex.RUnlock()
+	if a, ok := ledgerAccounts[account]; ok {
+		return a.balance()
+	}
+	return 0
+}
+
+func TestCheckLedgerEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []ledgerLine
+		wantErr string
+	}{
+		{"balanced", transferLedgerLines("a", "b", 100), ""},
+		{"split", []ledgerLine{{Account: "a", Debit: 100}, {Account: "b", Credit: 60}, {Account: "c", Credit: 40}}, ""},
+		{"no lines", nil, "has no lines"},
+		{"unbalanced", []ledgerLine{{Account: "a", Debit: 100}, {Account: "b", Credit: 99}}, "unbalanced"},
+		{"both sides", []ledgerLine{{Account: "a", Debit: 100, Credit: 100}}, "exactly one positive side"},
+		{"neither side", []ledgerLine{{Account: "a"}}, "exactly one positive side"},
+		{"negative", []ledgerLine{{Account: "a", Debit: -100}, {Account: "b", Credit: -100}}, "exactly one positive side"},
+		{"no account", []ledgerLine{{Debit: 100}, {Account: "b", Credit: 100}}, "has no account"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLedgerEntry(ledgerEntry{TransferID: "t", Lines: tt.lines})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkLedgerEntry() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkLedgerEntry() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostLedgerEntriesIsAllOrNothing(t *testing.T) {
+	resetLedger(t)
+
+	ledgerMutex.Lock()
+	err := postLedgerEntries([]ledgerEntry{
+		{TransferID: "t", Lines: transferLedgerLines("a", "b", 100)},
+		{TransferID: "t", Lines: []ledgerLine{{Account: "b", Debit: 100}, {Account: "c", Credit: 1}}},
+	})
+	posted := len(ledgerEntries)
+	accounts := len(ledgerAccounts)
+	ledgerMutex.Unlock()
+
+	if err == nil {
+		t.Fatal("postLedgerEntries() accepted an unbalanced entry")
+	}
+	if posted != 0 || accounts != 0 {
+		t.Errorf("posted %d entries touching %d accounts, want none", posted, accounts)
+	}
+}
+
+func TestApplyLedgerStatus(t *testing.T) {
+	source := fundingSource{CustomerURL: "https://api-sandbox.dwolla.com/customers/payer"}
+	destination := fundingSource{CustomerURL: "https://api-sandbox.dwolla.com/customers/payee"}
+	payer, payee := ledgerCustomerPrefix+"payer", ledgerCustomerPrefix+"payee"
+	fee := transferFee{Amount: "1.50", ChargeToID: "payee"}
+
+	tests := []struct {
+		name        string
+		fees        []transferFee
+		statuses    []string
+		wantState   string
+		wantPayer   int64
+		wantPayee   int64
+		wantFees    int64
+		wantEntries int
+	}{
+		{"pending", nil, nil, ledgerStatePending, -1000, 0, 0, 1},
+		{"completed", nil, []string{transferStatusProcessed}, ledgerStatePosted, -1000, 1000, 0, 2},
+		{"completed with fee", []transferFee{fee}, []string{transferStatusProcessed}, ledgerStatePosted, -1000, 850, 150, 3},
+		{"failed", nil, []string{transferStatusFailed}, ledgerStateReversed, 0, 0, 0, 2},
+		{"cancelled", nil, []string{transferStatusCancelled}, ledgerStateReversed, 0, 0, 0, 2},
+		{"returned after completing", []transferFee{fee}, []string{transferStatusProcessed, transferStatusFailed}, ledgerStateReversed, 0, 0, 0, 5},
+		{"repeated webhook", nil, []string{transferStatusProcessed, transferStatusProcessed}, ledgerStatePosted, -1000, 1000, 0, 2},
+		{"late completion after failure", nil, []string{transferStatusFailed, transferStatusProcessed}, ledgerStateReversed, 0, 0, 0, 2},
+		{"cancelled after completing", nil, []string{transferStatusProcessed, transferStatusCancelled}, ledgerStatePosted, -1000, 1000, 0, 2},
+		{"bad fee keeps the transfer pending", []transferFee{{Amount: "1.5x", ChargeToID: "payee"}}, []string{transferStatusProcessed}, ledgerStatePending, -1000, 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetLedger(t)
+
+			record := transferRecord{ID: "transfer-1", Amount: "10.00", Currency: "USD", Fees: tt.fees}
+			recordLedgerPending(record, source, destination)
+			for _, status := range tt.statuses {
+				applyLedgerStatus(record.ID, status, "test", "")
+			}
+
+			ledgerMutex.RLock()
+			state := ledgerTransfers[record.ID].State
+			entries := len(ledgerEntries)
+			ledgerMutex.RUnlock()
+
+			if state != tt.wantState {
+				t.Errorf("state = %s, want %s", state, tt.wantState)
+			}
+			if entries != tt.wantEntries {
+				t.Errorf("entries = %d, want %d", entries, tt.wantEntries)
+			}
+			if got := ledgerBalance(payer); got != tt.wantPayer {
+				t.Errorf("payer balance = %d, want %d", got, tt.wantPayer)
+			}
+			if got := ledgerBalance(payee); got != tt.wantPayee {
+				t.Errorf("payee balance = %d, want %d", got, tt.wantPayee)
+			}
+			if got := ledgerBalance(ledgerAccountPlatform); got != tt.wantFees {
+				t.Errorf("platform balance = %d, want %d", got, tt.wantFees)
+			}
+			assertLedgerBalanced(t)
+		})
+	}
+}
+
+func TestRecordLedgerPendingOnce(t *testing.T) {
+	resetLedger(t)
+
+	record := transferRecord{ID: "transfer-1", Amount: "10.00", Currency: "USD"}
+	recordLedgerPending(record, fundingSource{}, fundingSource{})
+	recordLedgerPending(record, fundingSource{}, fundingSource{})
+
+	if got := ledgerBalance(ledgerAccountInTransit); got != 1000 {
+		t.Errorf("in_transit = %d, want 1000", got)
+	}
+	assertLedgerBalanced(t)
+}
+
+func TestRecordLedgerMassPayment(t *testing.T) {
+	resetLedger(t)
+
+	payeeA := "https://api-sandbox.dwolla.com/funding-sources/a"
+	payeeB := "https://api-sandbox.dwolla.com/funding-sources/b"
+	record := massPaymentRecord{
+		ID:       "mp-1",
+		Currency: "USD",
+		Items: []massPaymentItem{
+			{Row: 1, DestinationURL: payeeA, Amount: "10.00"},
+			{Row: 2, DestinationURL: payeeB, Amount: "2.50"},
+			{Row: 3, DestinationURL: payeeA, Amount: "1.00"},
+		},
+	}
+	destinations := map[string]fundingSource{
+		payeeA: {CustomerURL: "https://api-sandbox.dwolla.com/customers/a"},
+		payeeB: {CustomerURL: "https://api-sandbox.dwolla.com/customers/b"},
+	}
+
+	recordLedgerMassPayment(record, fundingSource{}, destinations)
+	recordLedgerMassPayment(record, fundingSource{}, destinations)
+	if got := ledgerBalance(ledgerAccountPlatform); got != -1350 {
+		t.Fatalf("platform balance after submit = %d, want -1350", got)
+	}
+	assertLedgerBalanced(t)
+
+	// Items 1 and 3 succeed, which only creates their transfers; item 2 fails
+	linkLedgerMassPaymentItem("mp-1", 1, "item-transfer-1")
+	linkLedgerMassPaymentItem("mp-1", 3, "item-transfer-3")
+	applyLedgerStatus(massPaymentLedgerID("mp-1", 2), transferStatusFailed, "mass_payment_completed", "")
+
+	balances := func(step string, want map[string]int64) {
+		t.Helper()
+		for account, cents := range want {
+			if got := ledgerBalance(account); got != cents {
+				t.Errorf("%s: %s balance = %d, want %d", step, account, got, cents)
+			}
+		}
+		assertLedgerBalanced(t)
+	}
+	balances("items succeeded", map[string]int64{
+		ledgerAccountPlatform:      -1100,
+		ledgerAccountInTransit:     1100,
+		ledgerCustomerPrefix + "a": 0,
+		ledgerCustomerPrefix + "b": 0,
+	})
+
+	// The item transfers settle through the normal transfer status path
+	updateTransferStatus("item-transfer-1", transferStatusProcessed, "transfer_completed", "")
+	updateTransferStatus("item-transfer-3", transferStatusProcessed, "transfer_completed", "")
+	balances("item transfers completed", map[string]int64{
+		ledgerAccountPlatform:      -1100,
+		ledgerAccountInTransit:     0,
+		ledgerCustomerPrefix + "a": 1100,
+	})
+
+	// A returned payout is taken back from the payee
+	updateTransferStatus("item-transfer-3", transferStatusFailed, "transfer_failed", "")
+	balances("item transfer returned", map[string]int64{
+		ledgerAccountPlatform:      -1000,
+		ledgerCustomerPrefix + "a": 1000,
+	})
+
+	ledgerMutex.RLock()
+	item := *ledgerTransfers[massPaymentLedgerID("mp-1", 3)]
+	var itemEntries int
+	for _, entry := range ledgerEntries {
+		if entry.TransferID == item.TransferID {
+			itemEntries++
+		}
+	}
+	ledgerMutex.RUnlock()
+	if item.State != ledgerStateReversed || item.DwollaTransferID != "item-transfer-3" || itemEntries != 3 {
+		t.Errorf("item 3: state %s, transfer %q, %d entries; want reversed, item-transfer-3, 3", item.State, item.DwollaTransferID, itemEntries)
+	}
+}
+
+func TestRecordLedgerMassPaymentIsAllOrNothing(t *testing.T) {
+	resetLedger(t)
+
+	record := massPaymentRecord{
+		ID:       "mp-1",
+		Currency: "USD",
+		Items: []massPaymentItem{
+			{Row: 1, Amount: "10.00"},
+			{Row: 2, Amount: "ten"},
+		},
+	}
+	recordLedgerMassPayment(record, fundingSource{}, nil)
+
+	ledgerMutex.RLock()
+	entries, transfers := len(ledgerEntries), len(ledgerTransfers)
+	ledgerMutex.RUnlock()
+	if entries != 0 || transfers != 0 {
+		t.Errorf("booked %d entries and %d transfers, want none", entries, transfers)
+	}
+}
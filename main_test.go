@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain configures the package like setup does, without credentials or network access
+func TestMain(m *testing.M) {
+	DWOLLA_ENV = "sandbox"
+	DWOLLA_BASE_URL = "https://api-sandbox.dwolla.com"
+	os.Exit(m.Run())
+}
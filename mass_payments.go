@@ -0,0 +1,578 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dwolla accepts at most 5000 items per mass payment
+const maxMassPaymentItems = 5000
+
+// massPaymentRow is one requested payment before validation
+type massPaymentRow struct {
+	Destination   string            `json:"destination"`
+	Amount        float64           `json:"amount"`
+	CorrelationID string            `json:"correlationId"`
+	Metadata      map[string]string `json:"metadata"`
+
+	line       int    // CSV line, 0 for JSON items
+	parseError string // why a CSV line could not be read
+}
+
+// massPaymentItem is the local state of one mass payment item
+type massPaymentItem struct {
+	Row            int               `json:"row"`
+	DestinationURL string            `json:"destination_url"`
+	Amount         string            `json:"amount"`
+	CorrelationID  string            `json:"correlation_id,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	ItemID         string            `json:"item_id,omitempty"`
+	Status         string            `json:"status"` // "pending", "success" or "failed"
+	TransferURL    string            `json:"transfer_url,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// massPaymentRecord is the local state of a mass payment created through this service
+type massPaymentRecord struct {
+	ID            string            `json:"id"`
+	Href          string            `json:"href"`
+	SourceURL     string            `json:"source_url"`
+	Status        string            `json:"status"` // "deferred", "pending", "processing", "complete" or "cancelled"
+	Currency      string            `json:"currency"`
+	Total         string            `json:"total"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Items         []massPaymentItem `json:"items"`
+	CreatedAt     string            `json:"created_at"`
+	UpdatedAt     string            `json:"updated_at"`
+}
+
+var (
+	// Mass payments created through this service keyed by mass payment ID
+	massPayments     = map[string]*massPaymentRecord{}
+	massPaymentMutex sync.RWMutex
+)
+
+// massPaymentRowError describes why an input row was rejected
+type massPaymentRowError struct {
+	Row   int    `json:"row"`
+	Line  int    `json:"line,omitempty"` // CSV line
+	Error string `json:"error"`
+}
+
+// Destinations are checked with this many concurrent lookups
+const massPaymentCheckWorkers = 8
+
+// parseMassPaymentCSV reads rows of destination,amount[,correlationId] with an optional header line.
+// Lines that cannot be read are kept as rows with a parse error so every problem is reported at once.
+func parseMassPaymentCSV(r io.Reader) ([]massPaymentRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	rows := []massPaymentRow{}
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "destination") {
+			continue
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		row := massPaymentRow{line: i + 1}
+		if len(record) < 2 {
+			row.parseError = "expected destination,amount[,correlationId]"
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Destination = strings.TrimSpace(record[0])
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			row.parseError = fmt.Sprintf("invalid amount %q", record[1])
+		}
+		row.Amount = amount
+		if len(record) > 2 {
+			row.CorrelationID = strings.TrimSpace(record[2])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// validateMassPaymentRows validates every row up front and returns the items to submit
+func validateMassPaymentRows(rows []massPaymentRow) ([]massPaymentItem, int64, []massPaymentRowError) {
+	var items []massPaymentItem
+	var rowErrors []massPaymentRowError
+	var totalCents int64
+
+	if len(rows) == 0 {
+		return nil, 0, []massPaymentRowError{{Row: 0, Error: "at least one item is required"}}
+	}
+	if len(rows) > maxMassPaymentItems {
+		return nil, 0, []massPaymentRowError{{Row: 0, Error: fmt.Sprintf("at most %d items are allowed", maxMassPaymentItems)}}
+	}
+
+	for i, row := range rows {
+		rowNumber := i + 1
+		reject := func(message string) {
+			rowErrors = append(rowErrors, massPaymentRowError{Row: rowNumber, Line: row.line, Error: message})
+		}
+
+		if row.parseError != "" {
+			reject(row.parseError)
+			continue
+		}
+
+		_, destinationURL, err := resolveResource(resourceFundingSources, row.Destination)
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+
+		cents := amountToCents(row.Amount)
+		if cents <= 0 {
+			reject("amount must be at least 0.01")
+			continue
+		}
+
+		// One metadata key is reserved to match Dwolla items back to rows
+		if _, ok := row.Metadata["row"]; ok {
+			reject("metadata key 'row' is reserved")
+			continue
+		}
+		if len(row.Metadata) >= maxMetadataPairs {
+			reject(fmt.Sprintf("metadata may have at most %d keys", maxMetadataPairs-1))
+			continue
+		}
+		if err := validateTransferMetadata(row.CorrelationID, row.Metadata); err != nil {
+			reject(err.Error())
+			continue
+		}
+
+		totalCents += cents
+		items = append(items, massPaymentItem{
+			Row:            rowNumber,
+			DestinationURL: destinationURL,
+			Amount:         centsToAmount(cents),
+			CorrelationID:  row.CorrelationID,
+			Metadata:       row.Metadata,
+			Status:         "pending",
+		})
+	}
+
+	return items, totalCents, rowErrors
+}
+
+// checkMassPaymentDestinations refuses destinations that do not exist or whose customers are
+// suspended or deactivated, reporting every rejected row. Each destination is looked up once
+// and the funding sources found are returned by URL for the ledger.
+// A non-nil error means Dwolla could not be asked and nothing was checked.
+func checkMassPaymentDestinations(items []massPaymentItem) (map[string]fundingSource, []massPaymentRowError, int, error) {
+	type check struct {
+		fs     fundingSource
+		status int
+		err    error
+	}
+	destinations := map[string]*check{}
+	for _, item := range items {
+		destinations[item.DestinationURL] = &check{}
+	}
+
+	urls := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < massPaymentCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range urls {
+				fs, status, err := checkFundingSourceActive(url)
+				destinations[url].fs, destinations[url].status, destinations[url].err = fs, status, err
+			}
+		}()
+	}
+	for url := range destinations {
+		urls <- url
+	}
+	close(urls)
+	wg.Wait()
+
+	var rowErrors []massPaymentRowError
+	for _, item := range items {
+		result := destinations[item.DestinationURL]
+		if result.err == nil {
+			continue
+		}
+		if result.status >= http.StatusInternalServerError {
+			return nil, nil, result.status, result.err
+		}
+		rowErrors = append(rowErrors, massPaymentRowError{Row: item.Row, Error: result.err.Error()})
+	}
+
+	fundingSources := map[string]fundingSource{}
+	for url, result := range destinations {
+		fundingSources[url] = result.fs
+	}
+	return fundingSources, rowErrors, http.StatusOK, nil
+}
+
+// createMassPayment submits a Dwolla mass payment from a single source.
+// Accepts JSON {source, currency, correlationId, items: [{destination, amount, correlationId, metadata}]}
+// or a text/csv body of destination,amount[,correlationId] rows with ?source=<funding source>.
+// POST /api/dwolla/mass-payment
+func createMassPayment(c *gin.Context) {
+	var reqBody struct {
+		Source        string           `json:"source"`
+		Currency      string           `json:"currency"`
+		CorrelationID string           `json:"correlationId"`
+		Items         []massPaymentRow `json:"items"`
+	}
+
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		rows, err := parseMassPaymentCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reqBody.Source = c.Query("source")
+		reqBody.Currency = c.Query("currency")
+		reqBody.CorrelationID = c.Query("correlationId")
+		reqBody.Items = rows
+	} else if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateTransferMetadata(reqBody.CorrelationID, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, sourceURL, err := resolveResource(resourceFundingSources, reqBody.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source: " + err.Error()})
+		return
+	}
+
+	currency := reqBody.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+
+	items, totalCents, rowErrors := validateMassPaymentRows(reqBody.Items)
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "mass payment rejected, no items were submitted",
+			"errors": rowErrors,
+		})
+		return
+	}
+
+	source, status, err := checkFundingSourceActive(sourceURL)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	destinations, rowErrors, status, err := checkMassPaymentDestinations(items)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "mass payment rejected, no items were submitted",
+			"errors": rowErrors,
+		})
+		return
+	}
+
+	dwollaItems := []map[string]interface{}{}
+	for _, item := range items {
+		metadata := map[string]string{}
+		for key, value := range item.Metadata {
+			metadata[key] = value
+		}
+		metadata["row"] = strconv.Itoa(item.Row)
+		dwollaItem := map[string]interface{}{
+			"_links": map[string]interface{}{
+				"destination": map[string]string{"href": item.DestinationURL},
+			},
+			"amount": map[string]string{
+				"currency": currency,
+				"value":    item.Amount,
+			},
+			"metadata": metadata,
+		}
+		if item.CorrelationID != "" {
+			dwollaItem["correlationId"] = item.CorrelationID
+		}
+		dwollaItems = append(dwollaItems, dwollaItem)
+	}
+
+	payload := map[string]interface{}{
+		"_links": map[string]interface{}{
+			"source": map[string]string{"href": sourceURL},
+		},
+		"items": dwollaItems,
+	}
+	if reqBody.CorrelationID != "" {
+		payload["correlationId"] = reqBody.CorrelationID
+	}
+
+	url := DWOLLA_BASE_URL + "/mass-payments"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to create mass payment", "details": result})
+		return
+	}
+
+	massPaymentURL := result["location"].(string)
+	now := time.Now().Format(time.RFC3339)
+	record := &massPaymentRecord{
+		ID:            resourceIDFromHref(massPaymentURL),
+		Href:          massPaymentURL,
+		SourceURL:     sourceURL,
+		Status:        "pending",
+		Currency:      currency,
+		Total:         centsToAmount(totalCents),
+		CorrelationID: reqBody.CorrelationID,
+		Items:         items,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	massPaymentMutex.Lock()
+	massPayments[record.ID] = record
+	massPaymentMutex.Unlock()
+	recordLedgerMassPayment(*record, source, destinations)
+
+	fmt.Printf("Created mass payment with %d items (%s %s): %s\n", len(items), record.Total, currency, massPaymentURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"mass_payment_id":  record.ID,
+		"mass_payment_url": massPaymentURL,
+		"items":            len(items),
+		"total":            record.Total,
+		"status":           "created",
+	})
+}
+
+// refreshMassPayment pulls the mass payment status and item results from Dwolla into the local
+// record and the ledger. source is "api" or the webhook topic that triggered the refresh.
+func refreshMassPayment(massPaymentID, source, eventID string) error {
+	massPaymentMutex.RLock()
+	record, ok := massPayments[massPaymentID]
+	massPaymentMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("mass payment not found: %s", massPaymentID)
+	}
+
+	result, status, err := makeDwollaRequest("GET", record.Href, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to get mass payment: %v", result)
+	}
+	massPaymentStatus, _ := result["status"].(string)
+
+	// Item results are paged; follow the HAL next links
+	type itemResult struct {
+		itemID      string
+		status      string
+		transferURL string
+		errMessage  string
+	}
+	itemsByRow := map[int]itemResult{}
+	nextURL := record.Href + "/items?limit=200"
+	for nextURL != "" {
+		page, status, err := makeDwollaRequest("GET", nextURL, nil)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("failed to get mass payment items: %v", page)
+		}
+
+		embedded, _ := page["_embedded"].(map[string]interface{})
+		rawItems, _ := embedded["items"].([]interface{})
+		for _, raw := range rawItems {
+			item, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			metadata, _ := item["metadata"].(map[string]interface{})
+			rowValue, _ := metadata["row"].(string)
+			row, err := strconv.Atoi(rowValue)
+			if err != nil {
+				continue
+			}
+
+			links, _ := item["_links"].(map[string]interface{})
+			itemStatus, _ := item["status"].(string)
+			itemID, _ := item["id"].(string)
+			itemsByRow[row] = itemResult{
+				itemID:      itemID,
+				status:      itemStatus,
+				transferURL: linkHref(links, "transfer"),
+				errMessage:  massPaymentItemError(item),
+			}
+		}
+
+		pageLinks, _ := page["_links"].(map[string]interface{})
+		nextURL = linkHref(pageLinks, "next")
+	}
+
+	massPaymentMutex.Lock()
+	if massPaymentStatus != "" {
+		record.Status = massPaymentStatus
+	}
+	for i := range record.Items {
+		if item, ok := itemsByRow[record.Items[i].Row]; ok {
+			record.Items[i].ItemID = item.itemID
+			record.Items[i].Status = item.status
+			record.Items[i].TransferURL = item.transferURL
+			record.Items[i].Error = item.errMessage
+		}
+	}
+	record.UpdatedAt = time.Now().Format(time.RFC3339)
+	items := append([]massPaymentItem(nil), record.Items...)
+	massPaymentMutex.Unlock()
+
+	// A successful item only means Dwolla created its transfer, which stays in transit until
+	// its own transfer webhooks post or reverse it. Failed items, and every item of a cancelled
+	// mass payment, go back to the source. The ledger ignores repeated statuses.
+	for _, item := range items {
+		ledgerID := massPaymentLedgerID(massPaymentID, item.Row)
+		switch {
+		case massPaymentStatus == "cancelled":
+			applyLedgerStatus(ledgerID, transferStatusCancelled, source, eventID)
+		case item.Status == "success":
+			linkLedgerMassPaymentItem(massPaymentID, item.Row, resourceIDFromHref(item.TransferURL))
+		case item.Status == "failed":
+			applyLedgerStatus(ledgerID, transferStatusFailed, source, eventID)
+		}
+	}
+	return nil
+}
+
+// massPaymentItemError flattens the errors Dwolla reports on a failed item
+func massPaymentItemError(item map[string]interface{}) string {
+	embedded, _ := item["_embedded"].(map[string]interface{})
+	errorsValue, _ := embedded["errors"].([]interface{})
+	messages := []string{}
+	for _, raw := range errorsValue {
+		if e, ok := raw.(map[string]interface{}); ok {
+			if message, ok := e["message"].(string); ok {
+				messages = append(messages, message)
+			}
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// getMassPaymentRecord returns a copy of a mass payment record
+func getMassPaymentRecord(massPaymentID string) (massPaymentRecord, bool) {
+	massPaymentMutex.RLock()
+	defer massPaymentMutex.RUnlock()
+	record, ok := massPayments[massPaymentID]
+	if !ok {
+		return massPaymentRecord{}, false
+	}
+	copied := *record
+	copied.Items = append([]massPaymentItem(nil), record.Items...)
+	return copied, true
+}
+
+// getMassPayment returns a mass payment with per-item status, refreshed from Dwolla
+// GET /api/dwolla/mass-payment/:id
+func getMassPayment(c *gin.Context) {
+	massPaymentID := c.Param("id")
+	if _, ok := getMassPaymentRecord(massPaymentID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mass payment not found: " + massPaymentID})
+		return
+	}
+
+	if err := refreshMassPayment(massPaymentID, "api", ""); err != nil {
+		fmt.Printf("⚠ Failed to refresh mass payment %s: %v\n", massPaymentID, err)
+	}
+
+	record, _ := getMassPaymentRecord(massPaymentID)
+	c.JSON(http.StatusOK, record)
+}
+
+// getMassPaymentReport downloads the per-item results of a mass payment as CSV
+// GET /api/dwolla/mass-payment/:id/report
+func getMassPaymentReport(c *gin.Context) {
+	massPaymentID := c.Param("id")
+	record, ok := getMassPaymentRecord(massPaymentID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mass payment not found: " + massPaymentID})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=mass-payment-%s.csv", record.ID))
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"row", "destination", "amount", "currency", "correlation_id", "status", "transfer_url", "error"})
+	for _, item := range record.Items {
+		writer.Write([]string{
+			strconv.Itoa(item.Row),
+			item.DestinationURL,
+			item.Amount,
+			record.Currency,
+			item.CorrelationID,
+			item.Status,
+			item.TransferURL,
+			item.Error,
+		})
+	}
+	writer.Flush()
+}
+
+// handleMassPaymentWebhook refreshes item results on mass_payment_* webhooks.
+// It returns false when the topic is not a mass payment event.
+func handleMassPaymentWebhook(topic string, webhook map[string]interface{}) bool {
+	topic = strings.TrimPrefix(topic, "customer_")
+	if !strings.HasPrefix(topic, "mass_payment_") {
+		return false
+	}
+
+	links, _ := webhook["_links"].(map[string]interface{})
+	massPaymentID := resourceIDFromHref(linkHref(links, "resource"))
+	if _, ok := getMassPaymentRecord(massPaymentID); !ok {
+		fmt.Printf("ℹ Mass payment event for unknown mass payment: %s\n", massPaymentID)
+		return true
+	}
+
+	fmt.Printf("📦 Mass payment %s: %s\n", massPaymentID, strings.TrimPrefix(topic, "mass_payment_"))
+
+	// Fetch item results in the background so the webhook is acknowledged quickly
+	eventID, _ := webhook["id"].(string)
+	go func() {
+		if err := refreshMassPayment(massPaymentID, topic, eventID); err != nil {
+			log.Printf("❌ Failed to refresh mass payment %s: %v\n", massPaymentID, err)
+		}
+	}()
+	return true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMassPaymentRowsReportEveryError(t *testing.T) {
+	const destination = "11111111-1111-1111-1111-111111111111"
+	csv := strings.Join([]string{
+		"destination,amount,correlationId",
+		destination + ",10.00,first",
+		destination,                  // missing amount
+		destination + ",ten",         // bad amount
+		"not-an-id,5.00",             // bad destination
+		destination + ",0",           // zero amount
+		"",                           // blank lines are skipped
+		destination + ",2.50,second", // fine
+	}, "\n")
+
+	rows, err := parseMassPaymentCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("parseMassPaymentCSV() error = %v", err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("got %d rows, want 6", len(rows))
+	}
+
+	items, totalCents, rowErrors := validateMassPaymentRows(rows)
+	if len(items) != 2 || totalCents != 1250 {
+		t.Errorf("got %d items totalling %d cents, want 2 totalling 1250", len(items), totalCents)
+	}
+
+	want := []struct {
+		row, line int
+		error     string
+	}{
+		{2, 3, "expected destination,amount"},
+		{3, 4, "invalid amount"},
+		{4, 5, "invalid funding-sources ID"},
+		{5, 6, "at least 0.01"},
+	}
+	if len(rowErrors) != len(want) {
+		t.Fatalf("got %d row errors %+v, want %d", len(rowErrors), rowErrors, len(want))
+	}
+	for i, w := range want {
+		got := rowErrors[i]
+		if got.Row != w.row || got.Line != w.line || !strings.Contains(got.Error, w.error) {
+			t.Errorf("row error %d = %+v, want row %d line %d containing %q", i, got, w.row, w.line, w.error)
+		}
+	}
+}
+
+func TestMassPaymentRowsRejectReservedMetadata(t *testing.T) {
+	rows := []massPaymentRow{{
+		Destination: "11111111-1111-1111-1111-111111111111",
+		Amount:      1,
+		Metadata:    map[string]string{"row": "7"},
+	}}
+	if _, _, rowErrors := validateMassPaymentRows(rows); len(rowErrors) != 1 || !strings.Contains(rowErrors[0].Error, "reserved") {
+		t.Errorf("row errors = %+v, want the reserved key rejected", rowErrors)
+	}
+}
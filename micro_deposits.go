@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	routingNumberPattern = regexp.MustCompile(`^\d{9}$`)
+	accountNumberPattern = regexp.MustCompile(`^\d{4,17}$`)
+)
+
+// Micro-deposit states tracked from microdeposits_* webhooks
+const (
+	microDepositsInitiated   = "initiated"
+	microDepositsAdded       = "added"
+	microDepositsCompleted   = "completed"
+	microDepositsFailed      = "failed"
+	microDepositsMaxAttempts = "maxattempts"
+	microDepositsVerified    = "verified"
+)
+
+// microDepositState is the micro-deposit progress of a funding source
+type microDepositState struct {
+	FundingSourceID string `json:"funding_source_id"`
+	Status          string `json:"status"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+var (
+	microDepositStates = map[string]microDepositState{}
+	microDepositMutex  sync.RWMutex
+)
+
+// sensitiveAccountNumber holds a bank account number and masks itself under every fmt verb
+type sensitiveAccountNumber string
+
+// Format implements fmt.Formatter
+func (a sensitiveAccountNumber) Format(f fmt.State, verb rune) {
+	s := string(a)
+	if len(s) <= 4 {
+		io.WriteString(f, "****")
+		return
+	}
+	io.WriteString(f, "****"+s[len(s)-4:])
+}
+
+// validRoutingNumber checks an ABA routing number's length and checksum
+func validRoutingNumber(routingNumber string) bool {
+	if !routingNumberPattern.MatchString(routingNumber) {
+		return false
+	}
+
+	d := make([]int, 9)
+	for i, ch := range routingNumber {
+		d[i] = int(ch - '0')
+	}
+
+	sum := 3*(d[0]+d[3]+d[6]) + 7*(d[1]+d[4]+d[7]) + (d[2] + d[5] + d[8])
+	return sum%10 == 0
+}
+
+// setMicroDepositState records the micro-deposit status of a funding source
+func setMicroDepositState(fundingSourceID, status string) {
+	microDepositMutex.Lock()
+	microDepositStates[fundingSourceID] = microDepositState{
+		FundingSourceID: fundingSourceID,
+		Status:          status,
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+	microDepositMutex.Unlock()
+}
+
+// getMicroDepositState returns the tracked micro-deposit status of a funding source
+func getMicroDepositState(fundingSourceID string) (microDepositState, bool) {
+	microDepositMutex.RLock()
+	defer microDepositMutex.RUnlock()
+	state, ok := microDepositStates[fundingSourceID]
+	return state, ok
+}
+
+// initiateMicroDeposits asks Dwolla to send two micro-deposits to a funding source
+func initiateMicroDeposits(fundingSourceID, fundingSourceURL string) (int, error) {
+	result, status, err := makeDwollaRequest("POST", fundingSourceURL+"/micro-deposits", nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return status, fmt.Errorf("failed to initiate micro-deposits: %v", result)
+	}
+
+	setMicroDepositState(fundingSourceID, microDepositsInitiated)
+	fmt.Printf("💸 Initiated micro-deposits for funding source: %s\n", fundingSourceURL)
+	return http.StatusOK, nil
+}
+
+// createManualFundingSource adds a bank account from routing and account numbers and starts micro-deposit verification
+// POST /api/dwolla/funding-source/manual
+func createManualFundingSource(c *gin.Context) {
+	var reqBody struct {
+		CustomerURL     string                 `json:"customer_url"` // customer ID or URL
+		CustomerID      string                 `json:"customer_id"`
+		RoutingNumber   string                 `json:"routingNumber" binding:"required"`
+		AccountNumber   sensitiveAccountNumber `json:"accountNumber" binding:"required"`
+		BankAccountType string                 `json:"bankAccountType" binding:"required"`
+		Name            string                 `json:"name"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerRef := reqBody.CustomerURL
+	if customerRef == "" {
+		customerRef = reqBody.CustomerID
+	}
+	_, customerURL, err := resolveResource(resourceCustomers, customerRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validRoutingNumber(reqBody.RoutingNumber) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "routingNumber is not a valid ABA routing number"})
+		return
+	}
+	if !accountNumberPattern.MatchString(string(reqBody.AccountNumber)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "accountNumber must be 4 to 17 digits"})
+		return
+	}
+	bankAccountType := strings.ToLower(reqBody.BankAccountType)
+	if bankAccountType != "checking" && bankAccountType != "savings" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bankAccountType must be 'checking' or 'savings'"})
+		return
+	}
+
+	name := reqBody.Name
+	if name == "" {
+		name = "Bank Account"
+	}
+
+	payload := map[string]interface{}{
+		"routingNumber":   reqBody.RoutingNumber,
+		"accountNumber":   string(reqBody.AccountNumber),
+		"bankAccountType": bankAccountType,
+		"name":            name,
+	}
+
+	url := customerURL + "/funding-sources"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to create funding source", "details": result})
+		return
+	}
+
+	fundingSourceURL := result["location"].(string)
+	fundingSourceID := resourceIDFromHref(fundingSourceURL)
+	fmt.Printf("Created manual funding source (account %v): %s\n", reqBody.AccountNumber, fundingSourceURL)
+
+	response := gin.H{
+		"funding_source_id":  fundingSourceID,
+		"funding_source_url": fundingSourceURL,
+		"status":             "unverified",
+	}
+
+	if status, err := initiateMicroDeposits(fundingSourceID, fundingSourceURL); err != nil {
+		// The funding source exists; the client can retry initiation separately
+		response["micro_deposits"] = gin.H{"status": "not_initiated", "error": err.Error(), "code": status}
+	} else {
+		response["micro_deposits"] = gin.H{"status": microDepositsInitiated}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// startMicroDeposits (re)initiates micro-deposits for an unverified funding source
+// POST /api/dwolla/funding-source/:id/micro-deposits
+func startMicroDeposits(c *gin.Context) {
+	fundingSourceID, fundingSourceURL, err := resolveResource(resourceFundingSources, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := initiateMicroDeposits(fundingSourceID, fundingSourceURL); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding_source_id": fundingSourceID,
+		"status":            microDepositsInitiated,
+	})
+}
+
+// getMicroDeposits returns the micro-deposit status from Dwolla and from webhooks
+// GET /api/dwolla/funding-source/:id/micro-deposits
+func getMicroDeposits(c *gin.Context) {
+	fundingSourceID, fundingSourceURL, err := resolveResource(resourceFundingSources, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("GET", fundingSourceURL+"/micro-deposits", nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to get micro-deposits", "details": result})
+		return
+	}
+
+	response := gin.H{
+		"funding_source_id": fundingSourceID,
+		"dwolla_status":     result["status"],
+		"created":           result["created"],
+		"failure":           result["failure"],
+	}
+	if state, ok := getMicroDepositState(fundingSourceID); ok {
+		response["webhook_status"] = state
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// checkMicroDepositsCompleted refuses verification until the micro-deposits have settled.
+// Without a microdeposits_completed webhook, Dwolla's own micro-deposit status is used.
+func checkMicroDepositsCompleted(fundingSourceID, fundingSourceURL string) (int, error) {
+	if state, ok := getMicroDepositState(fundingSourceID); ok {
+		switch state.Status {
+		case microDepositsCompleted:
+			return http.StatusOK, nil
+		case microDepositsFailed, microDepositsMaxAttempts:
+			return http.StatusConflict, fmt.Errorf("micro-deposits cannot be verified: %s", state.Status)
+		case microDepositsVerified:
+			return http.StatusConflict, fmt.Errorf("funding source is already verified")
+		}
+	}
+
+	result, status, err := makeDwollaRequest("GET", fundingSourceURL+"/micro-deposits", nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return status, fmt.Errorf("failed to get micro-deposits: %v", result)
+	}
+	if dwollaStatus, _ := result["status"].(string); dwollaStatus != "processed" {
+		return http.StatusConflict, fmt.Errorf("micro-deposits have not completed yet (status %s)", dwollaStatus)
+	}
+
+	setMicroDepositState(fundingSourceID, microDepositsCompleted)
+	return http.StatusOK, nil
+}
+
+// verifyMicroDeposits submits the two micro-deposit amounts to verify a funding source
+// POST /api/dwolla/funding-source/:id/micro-deposits/verify
+func verifyMicroDeposits(c *gin.Context) {
+	var reqBody struct {
+		Amount1 float64 `json:"amount1" binding:"required"`
+		Amount2 float64 `json:"amount2" binding:"required"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fundingSourceID, fundingSourceURL, err := resolveResource(resourceFundingSources, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Micro-deposits are always below ten cents
+	for _, amount := range []float64{reqBody.Amount1, reqBody.Amount2} {
+		if amount <= 0 || amount >= 0.10 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "micro-deposit amounts must be between 0.01 and 0.09"})
+			return
+		}
+	}
+
+	if status, err := checkMicroDepositsCompleted(fundingSourceID, fundingSourceURL); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"amount1": map[string]interface{}{"value": fmt.Sprintf("%.2f", reqBody.Amount1), "currency": "USD"},
+		"amount2": map[string]interface{}{"value": fmt.Sprintf("%.2f", reqBody.Amount2), "currency": "USD"},
+	}
+
+	result, status, err := makeDwollaRequest("POST", fundingSourceURL+"/micro-deposits", payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK && status != http.StatusCreated {
+		c.JSON(status, gin.H{"error": "Failed to verify micro-deposits", "details": result})
+		return
+	}
+
+	setMicroDepositState(fundingSourceID, microDepositsVerified)
+	fmt.Printf("✓ Verified funding source with micro-deposits: %s\n", fundingSourceURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding_source_id":  fundingSourceID,
+		"funding_source_url": fundingSourceURL,
+		"status":             "verified",
+	})
+}
+
+// handleMicroDepositWebhook tracks microdeposits_* and customer_microdeposits_* webhooks, and
+// funding_source_verified for funding sources that are being verified with micro-deposits.
+// It returns false when the topic is not a micro-deposit event.
+func handleMicroDepositWebhook(topic string, webhook map[string]interface{}) bool {
+	topic = strings.TrimPrefix(topic, "customer_")
+	if !strings.HasPrefix(topic, "microdeposits_") && topic != "funding_source_verified" {
+		return false
+	}
+
+	links, _ := webhook["_links"].(map[string]interface{})
+	fundingSourceID := resourceIDFromHref(linkHref(links, "resource"))
+	if fundingSourceID == "" {
+		return true
+	}
+
+	switch topic {
+	case "microdeposits_added":
+		setMicroDepositState(fundingSourceID, microDepositsAdded)
+		fmt.Println("💸 Micro-deposits added, awaiting settlement")
+	case "microdeposits_completed":
+		setMicroDepositState(fundingSourceID, microDepositsCompleted)
+		fmt.Println("💸 Micro-deposits settled, ready to verify")
+	case "microdeposits_failed":
+		setMicroDepositState(fundingSourceID, microDepositsFailed)
+		fmt.Println("❌ Micro-deposits failed")
+	case "microdeposits_maxattempts":
+		setMicroDepositState(fundingSourceID, microDepositsMaxAttempts)
+		fmt.Println("❌ Micro-deposit verification attempts exhausted")
+	case "funding_source_verified":
+		// Funding sources verified another way have no micro-deposit state to update
+		if _, ok := getMicroDepositState(fundingSourceID); ok {
+			setMicroDepositState(fundingSourceID, microDepositsVerified)
+		}
+	default:
+		fmt.Printf("ℹ Micro-deposit event: %s\n", topic)
+	}
+
+	return true
+}
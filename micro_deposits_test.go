@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidRoutingNumber(t *testing.T) {
+	tests := []struct {
+		routingNumber string
+		want          bool
+	}{
+		{"222222226", true},  // Dwolla sandbox routing number
+		{"021000021", true},  // JPMorgan Chase
+		{"011000015", true},  // Federal Reserve Bank of Boston
+		{"222222225", false}, // bad check digit
+		{"021000022", false},
+		{"22222222", false}, // too short
+		{"2222222266", false},
+		{"22222222a", false},
+		{"", false},
+		{" 22222222", false},
+	}
+
+	for _, tt := range tests {
+		if got := validRoutingNumber(tt.routingNumber); got != tt.want {
+			t.Errorf("validRoutingNumber(%q) = %v, want %v", tt.routingNumber, got, tt.want)
+		}
+	}
+}
+
+func TestSensitiveAccountNumberIsMasked(t *testing.T) {
+	tests := []struct {
+		account sensitiveAccountNumber
+		want    string
+	}{
+		{"123456789", "****6789"},
+		{"1234", "****"},
+		{"", "****"},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []string{"%v", "%s", "%+v", "%q", "%#v"} {
+			if got := fmt.Sprintf(format, tt.account); got != tt.want {
+				t.Errorf("Sprintf(%q, %q) = %q, want %q", format, string(tt.account), got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// plaidItem is a linked Plaid item. Access tokens never leave the server.
+type plaidItem struct {
+	ItemID      string `json:"item_id"`
+	CustomerID  string `json:"customer_id"`
+	accessToken string
+	LinkedAt    string `json:"linked_at"`
+}
+
+var (
+	// Linked Plaid items keyed by item_id
+	plaidItems = map[string]*plaidItem{}
+	plaidMutex sync.RWMutex
+)
+
+// plaidError is the error body Plaid returns on non-200 responses
+type plaidError struct {
+	ErrorType    string `json:"error_type"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+	RequestID    string `json:"request_id"`
+}
+
+// getProcessorToken calls Plaid API to get a processor token
+func getProcessorToken() (string, error) {
+	url := PLAID_API_URL + "/api/sandbox/processor_token"
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte("{}")))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to get processor token, status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		ProcessorToken string `json:"processor_token"`
+		AccountID      string `json:"account_id"`
+		ItemID         string `json:"item_id"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Got processor_token from Plaid: %s\n", result.ProcessorToken)
+	return result.ProcessorToken, nil
+}
+
+// makePlaidRequest calls a Plaid API endpoint with the client credentials added to the body
+func makePlaidRequest(path string, body map[string]interface{}, out interface{}) (int, error) {
+	if PLAID_CLIENT_ID == "" || PLAID_SECRET == "" {
+		return http.StatusServiceUnavailable, fmt.Errorf("PLAID_CLIENT_ID and PLAID_SECRET must be set")
+	}
+
+	body["client_id"] = PLAID_CLIENT_ID
+	body["secret"] = PLAID_SECRET
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	req, err := http.NewRequest("POST", PLAID_BASE_URL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return http.StatusBadGateway, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var plaidErr plaidError
+		if err := json.Unmarshal(bodyBytes, &plaidErr); err != nil || plaidErr.ErrorCode == "" {
+			return resp.StatusCode, fmt.Errorf("plaid %s failed, status: %d, body: %s", path, resp.StatusCode, string(bodyBytes))
+		}
+		return resp.StatusCode, fmt.Errorf("plaid %s failed: %s (%s)", path, plaidErr.ErrorMessage, plaidErr.ErrorCode)
+	}
+
+	if err := json.Unmarshal(bodyBytes, out); err != nil {
+		return http.StatusBadGateway, fmt.Errorf("failed to parse Plaid response: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// getPlaidItem looks up a linked item by ID
+func getPlaidItem(itemID string) (*plaidItem, bool) {
+	plaidMutex.RLock()
+	defer plaidMutex.RUnlock()
+	item, ok := plaidItems[itemID]
+	return item, ok
+}
+
+// customerPlaidItem returns a linked item after checking that it was linked by the given
+// customer (ID or URL). An item can only be used for the customer that linked it.
+func customerPlaidItem(itemID, customerRef string) (*plaidItem, int, error) {
+	if customerRef == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("customer_id is required")
+	}
+	customerID, _, err := resolveResource(resourceCustomers, customerRef)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	item, ok := getPlaidItem(itemID)
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("plaid item not found: %s", itemID)
+	}
+	if item.CustomerID != customerID {
+		return nil, http.StatusForbidden, fmt.Errorf("plaid item %s was not linked by this customer", itemID)
+	}
+	return item, http.StatusOK, nil
+}
+
+// createPlaidProcessorToken creates a Dwolla processor token for one account of an item
+// linked by the customer
+func createPlaidProcessorToken(itemID, accountID, customerRef string) (string, int, error) {
+	item, status, err := customerPlaidItem(itemID, customerRef)
+	if err != nil {
+		return "", status, err
+	}
+
+	var result struct {
+		ProcessorToken string `json:"processor_token"`
+	}
+	body := map[string]interface{}{
+		"access_token": item.accessToken,
+		"account_id":   accountID,
+		"processor":    "dwolla",
+	}
+	if status, err := makePlaidRequest("/processor/token/create", body, &result); err != nil {
+		return "", status, err
+	}
+
+	fmt.Printf("Created Plaid processor token for item %s account %s\n", itemID, accountID)
+	return result.ProcessorToken, http.StatusOK, nil
+}
+
+// createPlaidLinkToken creates a Link token to initialize Plaid Link on the client
+// POST /api/plaid/link-token
+func createPlaidLinkToken(c *gin.Context) {
+	var reqBody struct {
+		CustomerID  string `json:"customer_id" binding:"required"` // Dwolla customer ID or URL
+		RedirectURI string `json:"redirect_uri"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, _, err := resolveResource(resourceCustomers, reqBody.CustomerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := map[string]interface{}{
+		"client_name":   "Dwolla Transfer Demo",
+		"language":      "en",
+		"country_codes": []string{"US"},
+		"products":      []string{"auth"},
+		"user": map[string]interface{}{
+			"client_user_id": customerID,
+		},
+	}
+	if reqBody.RedirectURI != "" {
+		body["redirect_uri"] = reqBody.RedirectURI
+	}
+
+	var result struct {
+		LinkToken  string `json:"link_token"`
+		Expiration string `json:"expiration"`
+	}
+	if status, err := makePlaidRequest("/link/token/create", body, &result); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"link_token": result.LinkToken,
+		"expiration": result.Expiration,
+	})
+}
+
+// exchangePlaidPublicToken exchanges a Link public_token for an access token stored server-side
+// POST /api/plaid/exchange
+func exchangePlaidPublicToken(c *gin.Context) {
+	var reqBody struct {
+		PublicToken string `json:"public_token" binding:"required"`
+		CustomerID  string `json:"customer_id" binding:"required"` // Dwolla customer ID or URL
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Items belong to the customer that linked them and can only fund that customer's accounts
+	customerID, _, err := resolveResource(resourceCustomers, reqBody.CustomerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ItemID      string `json:"item_id"`
+	}
+	body := map[string]interface{}{
+		"public_token": reqBody.PublicToken,
+	}
+	if status, err := makePlaidRequest("/item/public_token/exchange", body, &result); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := &plaidItem{
+		ItemID:      result.ItemID,
+		CustomerID:  customerID,
+		accessToken: result.AccessToken,
+		LinkedAt:    time.Now().Format(time.RFC3339),
+	}
+
+	plaidMutex.Lock()
+	plaidItems[item.ItemID] = item
+	plaidMutex.Unlock()
+
+	fmt.Printf("🔗 Linked Plaid item: %s\n", item.ItemID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":     item.ItemID,
+		"customer_id": item.CustomerID,
+		"status":      "linked",
+	})
+}
+
+// listPlaidAccounts lists the accounts of an item linked by the customer in ?customer_id=
+// GET /api/plaid/item/:item_id/accounts
+func listPlaidAccounts(c *gin.Context) {
+	itemID := c.Param("item_id")
+	item, status, err := customerPlaidItem(itemID, c.Query("customer_id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result struct {
+		Accounts []struct {
+			AccountID string `json:"account_id"`
+			Name      string `json:"name"`
+			Mask      string `json:"mask"`
+			Type      string `json:"type"`
+			Subtype   string `json:"subtype"`
+			Balances  struct {
+				Available *float64 `json:"available"`
+				Current   *float64 `json:"current"`
+				Currency  string   `json:"iso_currency_code"`
+			} `json:"balances"`
+		} `json:"accounts"`
+	}
+	body := map[string]interface{}{
+		"access_token": item.accessToken,
+	}
+	if status, err := makePlaidRequest("/accounts/get", body, &result); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	accounts := []gin.H{}
+	for _, account := range result.Accounts {
+		accounts = append(accounts, gin.H{
+			"account_id": account.AccountID,
+			"name":       account.Name,
+			"mask":       account.Mask,
+			"type":       account.Type,
+			"subtype":    account.Subtype,
+			"balances":   account.Balances,
+			// Dwolla only accepts checking and savings accounts
+			"eligible": account.Type == "depository" && (account.Subtype == "checking" || account.Subtype == "savings"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":  itemID,
+		"accounts": accounts,
+	})
+}
+
+// createProcessorToken creates a Dwolla processor token for a chosen account of an item
+// linked by the customer
+// POST /api/plaid/processor-token
+func createProcessorToken(c *gin.Context) {
+	var reqBody struct {
+		ItemID     string `json:"item_id" binding:"required"`
+		AccountID  string `json:"account_id" binding:"required"`
+		CustomerID string `json:"customer_id" binding:"required"` // Dwolla customer ID or URL
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	processorToken, status, err := createPlaidProcessorToken(reqBody.ItemID, reqBody.AccountID, reqBody.CustomerID)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":         reqBody.ItemID,
+		"account_id":      reqBody.AccountID,
+		"processor_token": processorToken,
+	})
+}
+
+// plaidFundingSourcePayload builds a funding source payload from a Plaid processor token for an
+// account of an item linked to the customer. The Plaid service's sandbox processor token, which is
+// the same test account every time, is only used when asked for explicitly in the sandbox.
+func plaidFundingSourcePayload(customerURL string, req fundingSourceRequest) (map[string]interface{}, int, error) {
+	if req.PlaidSandbox {
+		if req.PlaidItemID != "" || req.PlaidAccountID != "" {
+			return nil, http.StatusBadRequest, fmt.Errorf("plaid_sandbox cannot be combined with plaid_item_id and plaid_account_id")
+		}
+		if !strings.EqualFold(DWOLLA_ENV, "sandbox") {
+			return nil, http.StatusBadRequest, fmt.Errorf("plaid_sandbox is only available in the sandbox environment")
+		}
+		token, err := getProcessorToken()
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to get processor token from Plaid: %w", err)
+		}
+		return map[string]interface{}{"plaidToken": token}, http.StatusOK, nil
+	}
+
+	if req.PlaidItemID == "" || req.PlaidAccountID == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("plaid_item_id and plaid_account_id are required; link the account with Plaid Link first")
+	}
+	token, status, err := createPlaidProcessorToken(req.PlaidItemID, req.PlaidAccountID, customerURL)
+	if err != nil {
+		return nil, status, fmt.Errorf("failed to create processor token from Plaid: %w", err)
+	}
+	return map[string]interface{}{"plaidToken": token}, http.StatusOK, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCustomerPlaidItem(t *testing.T) {
+	owner := "11111111-1111-1111-1111-111111111111"
+	other := "22222222-2222-2222-2222-222222222222"
+
+	plaidMutex.Lock()
+	plaidItems["item-1"] = &plaidItem{ItemID: "item-1", CustomerID: owner}
+	plaidMutex.Unlock()
+	t.Cleanup(func() {
+		plaidMutex.Lock()
+		delete(plaidItems, "item-1")
+		plaidMutex.Unlock()
+	})
+
+	tests := []struct {
+		name       string
+		itemID     string
+		customer   string
+		wantStatus int
+	}{
+		{"owner by ID", "item-1", owner, http.StatusOK},
+		{"owner by URL", "item-1", DWOLLA_BASE_URL + "/customers/" + owner, http.StatusOK},
+		{"other customer", "item-1", other, http.StatusForbidden},
+		{"missing customer", "item-1", "", http.StatusBadRequest},
+		{"invalid customer", "item-1", "not-a-customer", http.StatusBadRequest},
+		{"unknown item", "item-2", owner, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, status, err := customerPlaidItem(tt.itemID, tt.customer)
+			if status != tt.wantStatus {
+				t.Fatalf("customerPlaidItem() status = %d, want %d (error %v)", status, tt.wantStatus, err)
+			}
+			if tt.wantStatus == http.StatusOK && (err != nil || item.ItemID != tt.itemID) {
+				t.Errorf("customerPlaidItem() = %v, %v", item, err)
+			}
+			if tt.wantStatus != http.StatusOK && err == nil {
+				t.Error("customerPlaidItem() returned no error")
+			}
+		})
+	}
+}
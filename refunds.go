@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refundMutex serializes refund creation so concurrent requests cannot together exceed the original amount
+var refundMutex sync.Mutex
+
+// refundsOf returns the local records of refunds of a transfer, oldest first
+func refundsOf(transferID string) []transferRecord {
+	transferMutex.RLock()
+	refunds := []transferRecord{}
+	for _, record := range transferRecords {
+		if record.RefundOf == transferID {
+			copied := *record
+			copied.History = append([]transferStatusEvent(nil), record.History...)
+			refunds = append(refunds, copied)
+		}
+	}
+	transferMutex.RUnlock()
+
+	sort.Slice(refunds, func(i, j int) bool {
+		return refunds[i].CreatedAt < refunds[j].CreatedAt
+	})
+	return refunds
+}
+
+// refundedCents totals the refunds of a transfer that have not failed or been cancelled.
+// A failed refund that is queued to be retried still counts, since the retry repeats it.
+func refundedCents(refunds []transferRecord) int64 {
+	var total int64
+	for _, refund := range refunds {
+		if refund.Status == transferStatusCancelled {
+			continue
+		}
+		if refund.Status == transferStatusFailed && !retryScheduled(refund.ID) {
+			continue
+		}
+		cents, err := parseAmountCents(refund.Amount)
+		if err != nil {
+			continue
+		}
+		total += cents
+	}
+	return total
+}
+
+// refundSummary describes the refund history of a transfer for lookups
+func refundSummary(originalAmount string, refunds []transferRecord) gin.H {
+	originalCents, _ := parseAmountCents(originalAmount)
+	refunded := refundedCents(refunds)
+	return gin.H{
+		"refunded":   centsToAmount(refunded),
+		"refundable": centsToAmount(originalCents - refunded),
+		"refunds":    refunds,
+	}
+}
+
+// checkRefundRetry refuses to retry a failed refund once the refunds of its transfer,
+// the retry included, would add up to more than the original amount. Refunds created
+// before the retry was scheduled may have used up what it refunded. The caller holds refundMutex.
+func checkRefundRetry(failed transferRecord) (int, error) {
+	result, status, err := makeDwollaRequest("GET", resourceHref(resourceTransfers, failed.RefundOf), nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status != http.StatusOK {
+		return status, fmt.Errorf("failed to get refunded transfer %s: %v", failed.RefundOf, result)
+	}
+	original, err := transferFromResult(result)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	originalCents, err := parseAmountCents(original.Amount.Value)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	refunded := refundedCents(refundsOf(failed.RefundOf))
+	if refunded > originalCents {
+		return http.StatusConflict, fmt.Errorf("refunds of transfer %s would total %s, more than the original %s", failed.RefundOf, centsToAmount(refunded), original.Amount.Value)
+	}
+	return http.StatusOK, nil
+}
+
+// refundTransfer creates a transfer in the opposite direction of a processed transfer.
+// Omitting amount refunds whatever has not been refunded yet. An optional Idempotency-Key
+// header is passed through to Dwolla.
+// POST /api/dwolla/transfer/:id/refund
+func refundTransfer(c *gin.Context) {
+	var reqBody struct {
+		Amount        float64           `json:"amount"`
+		Reason        string            `json:"reason"`
+		CorrelationID string            `json:"correlationId"`
+		Metadata      map[string]string `json:"metadata"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reqBody.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+	for _, key := range []string{"refund_of", "refund_reason"} {
+		if _, ok := reqBody.Metadata[key]; ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("metadata key '%s' is reserved", key)})
+			return
+		}
+	}
+
+	transferID, transferURL, err := resolveResource(resourceTransfers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("GET", transferURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to get transfer", "details": result})
+		return
+	}
+
+	original, err := transferFromResult(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if original.Status != transferStatusProcessed {
+		message := "only processed transfers can be refunded"
+		if original.Cancellable {
+			message += "; cancel the pending transfer instead"
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": message, "status": original.Status})
+		return
+	}
+	if record, ok := getTransferRecord(transferID); ok && record.RefundOf != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "a refund cannot itself be refunded", "refund_of": record.RefundOf})
+		return
+	}
+
+	originalCents, err := parseAmountCents(original.Amount.Value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	refundMutex.Lock()
+	defer refundMutex.Unlock()
+
+	refundable := originalCents - refundedCents(refundsOf(transferID))
+	cents := amountToCents(reqBody.Amount)
+	if cents == 0 {
+		cents = refundable
+	}
+	if refundable <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "transfer has already been fully refunded"})
+		return
+	}
+	if cents > refundable {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      fmt.Sprintf("refund of %s exceeds the refundable amount", centsToAmount(cents)),
+			"refundable": centsToAmount(refundable),
+		})
+		return
+	}
+
+	// Tag the refund in Dwolla so it can be reconciled without local state
+	metadata := map[string]string{}
+	for key, value := range reqBody.Metadata {
+		metadata[key] = value
+	}
+	metadata["refund_of"] = transferID
+	if reqBody.Reason != "" {
+		metadata["refund_reason"] = reqBody.Reason
+	}
+
+	req := transferRequest{
+		Source:        original.DestinationURL,
+		Destination:   original.SourceURL,
+		Amount:        float64(cents) / 100,
+		Currency:      original.Amount.Currency,
+		CorrelationID: reqBody.CorrelationID,
+		Metadata:      metadata,
+	}
+
+	refund, status, err := initiateTransfer(req, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferMutex.Lock()
+	if record, ok := transferRecords[refund.ID]; ok {
+		record.RefundOf = transferID
+		record.RefundReason = reqBody.Reason
+	}
+	transferMutex.Unlock()
+
+	fmt.Printf("↩ Refund of %s %s for transfer %s: %s\n", centsToAmount(cents), req.Currency, transferID, refund.Href)
+
+	c.JSON(http.StatusOK, gin.H{
+		"refund_id":            refund.ID,
+		"refund_url":           refund.Href,
+		"refund_of":            transferID,
+		"amount":               centsToAmount(cents),
+		"remaining_refundable": centsToAmount(refundable - cents),
+		"status":               "created",
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestRefundedCents(t *testing.T) {
+	retryMutex.Lock()
+	transferRetries["failed-retrying"] = &transferRetry{FailedTransferID: "failed-retrying", Status: retryStatusScheduled}
+	transferRetries["failed-retried"] = &transferRetry{FailedTransferID: "failed-retried", Status: retryStatusCreated}
+	transferRetries["failed-stopped"] = &transferRetry{FailedTransferID: "failed-stopped", Status: retryStatusStopped}
+	retryMutex.Unlock()
+	t.Cleanup(func() {
+		retryMutex.Lock()
+		delete(transferRetries, "failed-retrying")
+		delete(transferRetries, "failed-retried")
+		delete(transferRetries, "failed-stopped")
+		retryMutex.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		refunds []transferRecord
+		want    int64
+	}{
+		{"none", nil, 0},
+		{"pending and processed", []transferRecord{
+			{ID: "a", Amount: "10.00", Status: transferStatusPending},
+			{ID: "b", Amount: "2.50", Status: transferStatusProcessed},
+		}, 1250},
+		{"cancelled", []transferRecord{{ID: "a", Amount: "10.00", Status: transferStatusCancelled}}, 0},
+		{"failed without retry", []transferRecord{{ID: "failed-final", Amount: "10.00", Status: transferStatusFailed}}, 0},
+		{"failed with scheduled retry", []transferRecord{{ID: "failed-retrying", Amount: "10.00", Status: transferStatusFailed}}, 1000},
+		// The retry transfer carries the amount once it is created
+		{"failed with created retry", []transferRecord{
+			{ID: "failed-retried", Amount: "10.00", Status: transferStatusFailed},
+			{ID: "retry", Amount: "10.00", Status: transferStatusPending},
+		}, 1000},
+		{"failed with stopped retry", []transferRecord{{ID: "failed-stopped", Amount: "10.00", Status: transferStatusFailed}}, 0},
+		{"bad amount", []transferRecord{{ID: "a", Amount: "ten", Status: transferStatusPending}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refundedCents(tt.refunds); got != tt.want {
+				t.Errorf("refundedCents() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefundSummary(t *testing.T) {
+	tests := []struct {
+		name           string
+		original       string
+		refunds        []transferRecord
+		wantRefunded   string
+		wantRefundable string
+	}{
+		{"no refunds", "100.00", nil, "0.00", "100.00"},
+		{"partial", "100.00", []transferRecord{{ID: "a", Amount: "33.33", Status: transferStatusProcessed}}, "33.33", "66.67"},
+		{"full", "100.00", []transferRecord{
+			{ID: "a", Amount: "60.00", Status: transferStatusProcessed},
+			{ID: "b", Amount: "40.00", Status: transferStatusPending},
+		}, "100.00", "0.00"},
+		{"failed refund frees the amount", "100.00", []transferRecord{
+			{ID: "a", Amount: "60.00", Status: transferStatusFailed},
+			{ID: "b", Amount: "40.00", Status: transferStatusPending},
+		}, "40.00", "60.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := refundSummary(tt.original, tt.refunds)
+			if summary["refunded"] != tt.wantRefunded {
+				t.Errorf("refunded = %v, want %s", summary["refunded"], tt.wantRefunded)
+			}
+			if summary["refundable"] != tt.wantRefundable {
+				t.Errorf("refundable = %v, want %s", summary["refundable"], tt.wantRefundable)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Dwolla resource collections that clients may reference by ID or URL
+const (
+	resourceCustomers      = "customers"
+	resourceFundingSources = "funding-sources"
+	resourceTransfers      = "transfers"
+	resourceOwners         = "beneficial-owners"
+	resourceExchanges      = "exchanges"
+	resourcePartners       = "exchange-partners"
+)
+
+var resourceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// resolveResource normalizes a bare resource ID or a full Dwolla URL into the
+// resource ID and its canonical href on DWOLLA_BASE_URL.
+// URLs pointing at another host are rejected so the bearer token is never sent elsewhere.
+func resolveResource(kind, value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", fmt.Errorf("%s reference is empty", kind)
+	}
+
+	id := value
+	if strings.Contains(value, "/") {
+		parsed, err := url.Parse(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s URL: %s", kind, value)
+		}
+
+		base, err := url.Parse(DWOLLA_BASE_URL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid DWOLLA_BASE_URL: %w", err)
+		}
+		if !strings.EqualFold(parsed.Host, base.Host) {
+			return "", "", fmt.Errorf("%s URL must point at %s: %s", kind, base.Host, value)
+		}
+
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-2] != kind {
+			return "", "", fmt.Errorf("URL is not a Dwolla %s resource: %s", kind, value)
+		}
+		id = parts[len(parts)-1]
+	}
+
+	if !resourceIDPattern.MatchString(id) {
+		return "", "", fmt.Errorf("invalid %s ID: %s", kind, id)
+	}
+
+	id = strings.ToLower(id)
+	return id, resourceHref(kind, id), nil
+}
+
+// resourceHref builds the canonical Dwolla URL for a resource ID
+func resourceHref(kind, id string) string {
+	return strings.TrimRight(DWOLLA_BASE_URL, "/") + "/" + kind + "/" + id
+}
+
+// resourceIDFromHref returns the trailing ID segment of a Dwolla resource URL
+func resourceIDFromHref(href string) string {
+	href = strings.TrimRight(href, "/")
+	if i := strings.LastIndex(href, "/"); i >= 0 {
+		return href[i+1:]
+	}
+	return href
+}
+
+// decodeDwollaResource converts a parsed Dwolla response into a typed struct
+func decodeDwollaResource(result map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// selfHref returns the _links.self href of a Dwolla resource
+func selfHref(result map[string]interface{}) string {
+	links, _ := result["_links"].(map[string]interface{})
+	return linkHref(links, "self")
+}
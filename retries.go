@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NACHA allows a returned entry to be reinitiated at most twice
+const maxRetryAttempts = 2
+
+// Retry statuses
+const (
+	retryStatusScheduled = "scheduled"
+	retryStatusCreated   = "created"
+	retryStatusFailed    = "failed"
+	retryStatusStopped   = "stopped"
+)
+
+// retryPolicy controls how transfers returned with a given code are retried
+type retryPolicy struct {
+	ReturnCode        string `json:"return_code"`
+	MaxAttempts       int    `json:"max_attempts"`
+	DelayBusinessDays int    `json:"delay_business_days"`
+}
+
+// transferRetry is a retry of a failed transfer, scheduled or done
+type transferRetry struct {
+	FailedTransferID   string `json:"failed_transfer_id"`
+	OriginalTransferID string `json:"original_transfer_id"`
+	ReturnCode         string `json:"return_code"`
+	Attempt            int    `json:"attempt"`
+	DueDate            string `json:"due_date,omitempty"`
+	Status             string `json:"status"`
+	RetryTransferID    string `json:"retry_transfer_id,omitempty"`
+	RetryTransferURL   string `json:"retry_transfer_url,omitempty"`
+	Reason             string `json:"reason,omitempty"` // why the retry was stopped or failed
+	UpdatedAt          string `json:"updated_at"`
+}
+
+var (
+	// Retry policies keyed by return code. R01 and R09 are retried by default.
+	retryPolicies = map[string]retryPolicy{
+		"R01": {ReturnCode: "R01", MaxAttempts: 2, DelayBusinessDays: 2},
+		"R09": {ReturnCode: "R09", MaxAttempts: 2, DelayBusinessDays: 2},
+	}
+
+	// Retries keyed by the ID of the failed transfer they replace
+	transferRetries = map[string]*transferRetry{}
+	retryMutex      sync.RWMutex
+)
+
+// scheduleTransferRetry decides whether a failed transfer is retried and queues the retry
+func scheduleTransferRetry(record transferRecord, failure transferFailure) {
+	originalID := record.RetryOf
+	if originalID == "" {
+		originalID = record.ID
+	}
+
+	retry := &transferRetry{
+		FailedTransferID:   record.ID,
+		OriginalTransferID: originalID,
+		ReturnCode:         failure.Code,
+		Attempt:            record.RetryAttempt + 1,
+		Status:             retryStatusScheduled,
+		UpdatedAt:          time.Now().Format(time.RFC3339),
+	}
+
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+
+	if _, exists := transferRetries[record.ID]; exists {
+		return
+	}
+
+	policy, hasPolicy := retryPolicies[failure.Code]
+	switch {
+	case !failure.Retryable:
+		retry.Status = retryStatusStopped
+		retry.Reason = fmt.Sprintf("return code %s is not retryable", failure.Code)
+	case !hasPolicy:
+		retry.Status = retryStatusStopped
+		retry.Reason = fmt.Sprintf("no retry policy for return code %s", failure.Code)
+	case retry.Attempt > policy.MaxAttempts:
+		retry.Status = retryStatusStopped
+		retry.Reason = fmt.Sprintf("retry attempts exhausted (%d)", policy.MaxAttempts)
+	default:
+		retry.DueDate = addBusinessDays(scheduleToday(), policy.DelayBusinessDays).Format(scheduleDateLayout)
+	}
+	transferRetries[record.ID] = retry
+
+	if retry.Status == retryStatusScheduled {
+		fmt.Printf("🔁 Transfer %s (%s) will be retried on %s, attempt %d of %d\n",
+			record.ID, failure.Code, retry.DueDate, retry.Attempt, policy.MaxAttempts)
+	} else {
+		fmt.Printf("⏹ Transfer %s will not be retried: %s\n", record.ID, retry.Reason)
+	}
+}
+
+// retryWorker creates retry transfers once they are due
+func retryWorker() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runDueRetries()
+	}
+}
+
+// runDueRetries creates every scheduled retry whose due date has arrived
+func runDueRetries() {
+	today := scheduleToday().Format(scheduleDateLayout)
+
+	retryMutex.RLock()
+	var due []string
+	for id, retry := range transferRetries {
+		if retry.Status == retryStatusScheduled && retry.DueDate <= today {
+			due = append(due, id)
+		}
+	}
+	retryMutex.RUnlock()
+
+	for _, id := range due {
+		executeTransferRetry(id)
+	}
+}
+
+// executeTransferRetry creates a new transfer repeating a failed one and links the two.
+// The idempotency key is derived from the failed transfer, so a retry is created at most once.
+func executeTransferRetry(failedTransferID string) {
+	failed, ok := getTransferRecord(failedTransferID)
+	if !ok {
+		return
+	}
+
+	retryMutex.RLock()
+	retry, ok := transferRetries[failedTransferID]
+	attempt := 0
+	if ok {
+		attempt = retry.Attempt
+	}
+	retryMutex.RUnlock()
+	if !ok {
+		return
+	}
+
+	req, err := transferRequestFromRecord(failed)
+	if err != nil {
+		finishTransferRetry(failedTransferID, retryStatusFailed, transferRecord{}, err.Error())
+		return
+	}
+
+	// A refund is re-checked under the refund lock, like a new one
+	if failed.RefundOf != "" {
+		refundMutex.Lock()
+		defer refundMutex.Unlock()
+		if status, err := checkRefundRetry(failed); err != nil {
+			if status >= http.StatusInternalServerError {
+				log.Printf("❌ Retry of refund %s could not be checked, will try again: %v\n", failedTransferID, err)
+				return
+			}
+			finishTransferRetry(failedTransferID, retryStatusStopped, transferRecord{}, err.Error())
+			fmt.Printf("⏹ Refund %s will not be retried: %v\n", failedTransferID, err)
+			return
+		}
+	}
+
+	// The retry keeps the original correlationId so every attempt reconciles to the same payment
+	record, status, err := initiateTransfer(req, "retry-"+failedTransferID)
+	if err != nil {
+		if status >= http.StatusInternalServerError {
+			log.Printf("❌ Retry of transfer %s failed, will try again: %v\n", failedTransferID, err)
+			return
+		}
+		finishTransferRetry(failedTransferID, retryStatusFailed, transferRecord{}, err.Error())
+		fmt.Printf("❌ Retry of transfer %s was rejected: %v\n", failedTransferID, err)
+		return
+	}
+
+	linkRetryTransfer(failed, record.ID, attempt)
+	finishTransferRetry(failedTransferID, retryStatusCreated, record, "")
+	fmt.Printf("🔁 Retried transfer %s as %s (attempt %d)\n", failedTransferID, record.Href, attempt)
+}
+
+// transferRequestFromRecord rebuilds the request that created a transfer
+func transferRequestFromRecord(record transferRecord) (transferRequest, error) {
+	cents, err := parseAmountCents(record.Amount)
+	if err != nil {
+		return transferRequest{}, err
+	}
+
+	req := transferRequest{
+		Source:        record.SourceURL,
+		Destination:   record.DestinationURL,
+		Amount:        float64(cents) / 100,
+		Currency:      record.Currency,
+		CorrelationID: record.CorrelationID,
+		Metadata:      record.Metadata,
+	}
+	// Same-day, addenda and real-time payments are retried the same way
+	if record.Options != nil {
+		req.transferOptions = *record.Options
+	}
+	for _, fee := range record.Fees {
+		feeCents, err := parseAmountCents(fee.Amount)
+		if err != nil {
+			return transferRequest{}, err
+		}
+		req.Fees = append(req.Fees, transferFeeRequest{Amount: float64(feeCents) / 100, ChargeTo: fee.ChargeTo})
+	}
+	return req, nil
+}
+
+// linkRetryTransfer records the retry chain on both the failed and the new transfer
+func linkRetryTransfer(failed transferRecord, retryTransferID string, attempt int) {
+	originalID := failed.RetryOf
+	if originalID == "" {
+		originalID = failed.ID
+	}
+
+	transferMutex.Lock()
+	defer transferMutex.Unlock()
+	if record, ok := transferRecords[failed.ID]; ok {
+		record.RetryTransferID = retryTransferID
+	}
+	if record, ok := transferRecords[retryTransferID]; ok {
+		record.RetryOf = originalID
+		record.RetryAttempt = attempt
+		// A retried refund still counts against the transfer it refunds
+		record.RefundOf = failed.RefundOf
+		record.RefundReason = failed.RefundReason
+	}
+}
+
+// retryScheduled reports whether a failed transfer is queued to be retried
+func retryScheduled(failedTransferID string) bool {
+	retryMutex.RLock()
+	defer retryMutex.RUnlock()
+	retry, ok := transferRetries[failedTransferID]
+	return ok && retry.Status == retryStatusScheduled
+}
+
+// finishTransferRetry records the outcome of a retry
+func finishTransferRetry(failedTransferID, status string, record transferRecord, reason string) {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	retry, ok := transferRetries[failedTransferID]
+	if !ok {
+		return
+	}
+	retry.Status = status
+	retry.RetryTransferID = record.ID
+	retry.RetryTransferURL = record.Href
+	retry.Reason = reason
+	retry.UpdatedAt = time.Now().Format(time.RFC3339)
+}
+
+// listRetryPolicies returns the configured retry policies
+// GET /api/dwolla/retry-policies
+func listRetryPolicies(c *gin.Context) {
+	retryMutex.RLock()
+	policies := []retryPolicy{}
+	for _, policy := range retryPolicies {
+		policies = append(policies, policy)
+	}
+	retryMutex.RUnlock()
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].ReturnCode < policies[j].ReturnCode
+	})
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// setRetryPolicy creates or replaces the retry policy for a retryable return code
+// POST /api/dwolla/retry-policy
+func setRetryPolicy(c *gin.Context) {
+	var reqBody struct {
+		ReturnCode        string `json:"return_code" binding:"required"`
+		MaxAttempts       int    `json:"max_attempts" binding:"required"`
+		DelayBusinessDays int    `json:"delay_business_days"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rc, ok := lookupReturnCode(reqBody.ReturnCode)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown return code: " + reqBody.ReturnCode})
+		return
+	}
+	if !rc.Retryable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("return code %s (%s) is not retryable", rc.Code, rc.Description)})
+		return
+	}
+	if reqBody.MaxAttempts < 1 || reqBody.MaxAttempts > maxRetryAttempts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("max_attempts must be between 1 and %d", maxRetryAttempts)})
+		return
+	}
+	if reqBody.DelayBusinessDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delay_business_days must not be negative"})
+		return
+	}
+
+	policy := retryPolicy{
+		ReturnCode:        rc.Code,
+		MaxAttempts:       reqBody.MaxAttempts,
+		DelayBusinessDays: reqBody.DelayBusinessDays,
+	}
+
+	retryMutex.Lock()
+	retryPolicies[rc.Code] = policy
+	retryMutex.Unlock()
+
+	fmt.Printf("🔁 Retry policy for %s: %d attempts, %d business days apart\n", rc.Code, policy.MaxAttempts, policy.DelayBusinessDays)
+	c.JSON(http.StatusOK, policy)
+}
+
+// deleteRetryPolicy stops retrying transfers returned with a code
+// DELETE /api/dwolla/retry-policy/:code
+func deleteRetryPolicy(c *gin.Context) {
+	rc, ok := lookupReturnCode(c.Param("code"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown return code: " + c.Param("code")})
+		return
+	}
+
+	retryMutex.Lock()
+	_, exists := retryPolicies[rc.Code]
+	delete(retryPolicies, rc.Code)
+	retryMutex.Unlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no retry policy for return code " + rc.Code})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"return_code": rc.Code,
+		"status":      "deleted",
+	})
+}
+
+// listTransferRetries lists scheduled and completed retries, optionally filtered by ?status=
+// GET /api/dwolla/retries
+func listTransferRetries(c *gin.Context) {
+	status := c.Query("status")
+
+	retryMutex.RLock()
+	retries := []transferRetry{}
+	for _, retry := range transferRetries {
+		if status != "" && retry.Status != status {
+			continue
+		}
+		retries = append(retries, *retry)
+	}
+	retryMutex.RUnlock()
+
+	sort.Slice(retries, func(i, j int) bool {
+		return retries[i].UpdatedAt < retries[j].UpdatedAt
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(retries),
+		"retries": retries,
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransferRequestFromRecord(t *testing.T) {
+	var options transferOptions
+	options.Clearing.Destination = clearingNextAvailable
+	options.Addenda.Source = "INVOICE 42"
+	options.ProcessingChannel = channelRealTime
+
+	record := transferRecord{
+		SourceURL:      "https://api-sandbox.dwolla.com/funding-sources/a",
+		DestinationURL: "https://api-sandbox.dwolla.com/funding-sources/b",
+		Amount:         "12.34",
+		Currency:       "USD",
+		CorrelationID:  "order-42",
+		Metadata:       map[string]string{"order": "42"},
+		Fees:           []transferFee{{Amount: "0.50", ChargeTo: "https://api-sandbox.dwolla.com/customers/c"}},
+		Options:        &options,
+	}
+
+	req, err := transferRequestFromRecord(record)
+	if err != nil {
+		t.Fatalf("transferRequestFromRecord() error = %v", err)
+	}
+
+	want := transferRequest{
+		Source:          record.SourceURL,
+		Destination:     record.DestinationURL,
+		Amount:          12.34,
+		Currency:        "USD",
+		CorrelationID:   "order-42",
+		Metadata:        map[string]string{"order": "42"},
+		Fees:            []transferFeeRequest{{Amount: 0.5, ChargeTo: "https://api-sandbox.dwolla.com/customers/c"}},
+		transferOptions: options,
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("transferRequestFromRecord() = %+v, want %+v", req, want)
+	}
+
+	record.Options = nil
+	req, _ = transferRequestFromRecord(record)
+	if req.transferOptions != (transferOptions{}) {
+		t.Errorf("record without options rebuilt with %+v", req.transferOptions)
+	}
+}
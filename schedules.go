@@ -0,0 +1,514 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schedule recurrence frequencies
+const (
+	scheduleFrequencyOnce    = "once"
+	scheduleFrequencyDaily   = "daily"
+	scheduleFrequencyWeekly  = "weekly"
+	scheduleFrequencyMonthly = "monthly"
+)
+
+// Schedule statuses
+const (
+	scheduleStatusActive    = "active"
+	scheduleStatusPaused    = "paused"
+	scheduleStatusCompleted = "completed"
+	scheduleStatusCancelled = "cancelled"
+)
+
+// Schedule dates are calendar days in UTC
+const scheduleDateLayout = "2006-01-02"
+
+// transferSchedule is a one-off or recurring transfer
+type transferSchedule struct {
+	ID             string              `json:"id"`
+	Transfer       transferRequest     `json:"transfer"`
+	Frequency      string              `json:"frequency"`
+	Interval       int                 `json:"interval"` // every N days, weeks or months
+	StartDate      string              `json:"start_date"`
+	EndDate        string              `json:"end_date,omitempty"`
+	MaxOccurrences int                 `json:"max_occurrences,omitempty"`
+	Occurrences    int                 `json:"occurrences"` // occurrences executed so far
+	NextRunDate    string              `json:"next_run_date,omitempty"`
+	Status         string              `json:"status"`
+	Executions     []scheduleExecution `json:"executions"`
+	CreatedAt      string              `json:"created_at"`
+	UpdatedAt      string              `json:"updated_at"`
+}
+
+// scheduleExecution is the outcome of one occurrence of a schedule
+type scheduleExecution struct {
+	Occurrence     int    `json:"occurrence"`
+	ScheduledDate  string `json:"scheduled_date"` // date from the recurrence rule
+	RunDate        string `json:"run_date"`       // scheduled date moved to a business day
+	IdempotencyKey string `json:"idempotency_key"`
+	Status         string `json:"status"` // "created", "failed" or "skipped"
+	TransferID     string `json:"transfer_id,omitempty"`
+	TransferURL    string `json:"transfer_url,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ExecutedAt     string `json:"executed_at"`
+}
+
+var (
+	transferSchedules = map[string]*transferSchedule{}
+	scheduleMutex     sync.RWMutex
+)
+
+// newScheduleID returns a random identifier for a schedule
+func newScheduleID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// scheduleToday returns the current calendar day in UTC
+func scheduleToday() time.Time {
+	year, month, day := time.Now().UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// occurrenceDate returns the date the recurrence rule gives for the nth occurrence (zero based).
+// Computing from the start date keeps monthly schedules anchored to their original day.
+func (s *transferSchedule) occurrenceDate(n int) time.Time {
+	start, _ := time.Parse(scheduleDateLayout, s.StartDate)
+	switch s.Frequency {
+	case scheduleFrequencyDaily:
+		return start.AddDate(0, 0, n*s.Interval)
+	case scheduleFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n*s.Interval)
+	case scheduleFrequencyMonthly:
+		// Clamp to the end of shorter months instead of rolling into the next one
+		target := time.Date(start.Year(), start.Month()+time.Month(n*s.Interval), 1, 0, 0, 0, 0, time.UTC)
+		lastDay := target.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return time.Date(target.Year(), target.Month(), day, 0, 0, 0, 0, time.UTC)
+	}
+	return start
+}
+
+// limitReached reports whether the schedule may not run its nth occurrence (zero based)
+// because of its frequency, end date or occurrence limit
+func (s *transferSchedule) limitReached(n int) bool {
+	if (s.Frequency == scheduleFrequencyOnce && n > 0) || (s.MaxOccurrences > 0 && n >= s.MaxOccurrences) {
+		return true
+	}
+	if s.EndDate != "" {
+		end, _ := time.Parse(scheduleDateLayout, s.EndDate)
+		return s.occurrenceDate(n).After(end)
+	}
+	return false
+}
+
+// runDate is the business day the nth occurrence runs on. Payments due on weekends and
+// holidays run on the next business day.
+func (s *transferSchedule) runDate(n int) time.Time {
+	return nextBusinessDay(s.occurrenceDate(n))
+}
+
+// missed reports whether the nth occurrence was missed: its run date is before today and a
+// later occurrence is due by today. Occurrences due on a weekend or holiday that roll onto
+// today's business day are not missed.
+func (s *transferSchedule) missed(n int, today time.Time) bool {
+	return s.runDate(n).Before(today) && !s.limitReached(n+1) && !s.runDate(n+1).After(today)
+}
+
+// planNextRun sets the next run date from the occurrence count, completing the schedule
+// once its frequency, end date or occurrence limit is reached. After a pause or outage only
+// the most recent missed occurrence runs; earlier ones are recorded as skipped and still
+// count toward max_occurrences.
+func (s *transferSchedule) planNextRun(today time.Time) {
+	s.NextRunDate = ""
+
+	for s.Status == scheduleStatusActive && s.missed(s.Occurrences, today) {
+		s.Executions = append(s.Executions, scheduleExecution{
+			Occurrence:    s.Occurrences + 1,
+			ScheduledDate: s.occurrenceDate(s.Occurrences).Format(scheduleDateLayout),
+			RunDate:       s.runDate(s.Occurrences).Format(scheduleDateLayout),
+			Status:        "skipped",
+			ExecutedAt:    time.Now().Format(time.RFC3339),
+		})
+		fmt.Printf("⏭ Schedule %s occurrence %d missed, skipped\n", s.ID, s.Occurrences+1)
+		s.Occurrences++
+	}
+
+	if s.limitReached(s.Occurrences) {
+		s.Status = scheduleStatusCompleted
+		return
+	}
+	s.NextRunDate = s.runDate(s.Occurrences).Format(scheduleDateLayout)
+}
+
+// validateScheduleRule checks the recurrence fields of a schedule
+func validateScheduleRule(s *transferSchedule) error {
+	switch s.Frequency {
+	case scheduleFrequencyOnce, scheduleFrequencyDaily, scheduleFrequencyWeekly, scheduleFrequencyMonthly:
+	default:
+		return fmt.Errorf("frequency must be one of once, daily, weekly or monthly")
+	}
+	if s.Interval == 0 {
+		s.Interval = 1
+	}
+	if s.Interval < 0 {
+		return fmt.Errorf("interval must be at least 1")
+	}
+	if s.MaxOccurrences < 0 {
+		return fmt.Errorf("max_occurrences must not be negative")
+	}
+
+	start, err := time.Parse(scheduleDateLayout, s.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be a date in YYYY-MM-DD format")
+	}
+	if s.EndDate != "" {
+		end, err := time.Parse(scheduleDateLayout, s.EndDate)
+		if err != nil {
+			return fmt.Errorf("end_date must be a date in YYYY-MM-DD format")
+		}
+		if end.Before(start) {
+			return fmt.Errorf("end_date must not be before start_date")
+		}
+	}
+	return nil
+}
+
+// getScheduleCopy returns a copy of a schedule
+func getScheduleCopy(scheduleID string) (transferSchedule, bool) {
+	scheduleMutex.RLock()
+	defer scheduleMutex.RUnlock()
+	schedule, ok := transferSchedules[scheduleID]
+	if !ok {
+		return transferSchedule{}, false
+	}
+	copied := *schedule
+	copied.Executions = append([]scheduleExecution(nil), schedule.Executions...)
+	return copied, true
+}
+
+// scheduleWorker executes due schedule occurrences
+func scheduleWorker() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runDueSchedules()
+	}
+}
+
+// runDueSchedules executes every active schedule whose next run date has arrived
+func runDueSchedules() {
+	today := scheduleToday().Format(scheduleDateLayout)
+
+	scheduleMutex.RLock()
+	var due []string
+	for id, schedule := range transferSchedules {
+		if schedule.Status == scheduleStatusActive && schedule.NextRunDate != "" && schedule.NextRunDate <= today {
+			due = append(due, id)
+		}
+	}
+	scheduleMutex.RUnlock()
+
+	for _, id := range due {
+		executeScheduleOccurrence(id)
+	}
+}
+
+// executeScheduleOccurrence initiates the transfer for the next occurrence of a schedule.
+// Each occurrence has its own idempotency key, so retrying after a lost response never
+// creates a second transfer. Transient failures are retried on the next tick; rejected
+// transfers are recorded as failed and the schedule moves on.
+func executeScheduleOccurrence(scheduleID string) {
+	schedule, ok := getScheduleCopy(scheduleID)
+	if !ok || schedule.Status != scheduleStatusActive {
+		return
+	}
+
+	occurrence := schedule.Occurrences
+	idempotencyKey := fmt.Sprintf("schedule-%s-%d", schedule.ID, occurrence)
+
+	record, status, err := initiateTransfer(schedule.Transfer, idempotencyKey)
+	if err != nil && status >= http.StatusInternalServerError {
+		log.Printf("❌ Schedule %s occurrence %d failed, will retry: %v\n", scheduleID, occurrence+1, err)
+		return
+	}
+
+	execution := scheduleExecution{
+		Occurrence:     occurrence + 1,
+		ScheduledDate:  schedule.occurrenceDate(occurrence).Format(scheduleDateLayout),
+		RunDate:        schedule.NextRunDate,
+		IdempotencyKey: idempotencyKey,
+		Status:         "created",
+		TransferID:     record.ID,
+		TransferURL:    record.Href,
+		ExecutedAt:     time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		execution.Status = "failed"
+		execution.Error = err.Error()
+		fmt.Printf("❌ Schedule %s occurrence %d rejected: %v\n", scheduleID, occurrence+1, err)
+	} else {
+		fmt.Printf("🗓 Schedule %s occurrence %d created transfer: %s\n", scheduleID, occurrence+1, record.Href)
+	}
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	current, ok := transferSchedules[scheduleID]
+	if !ok || current.Occurrences != occurrence {
+		return
+	}
+	current.Executions = append(current.Executions, execution)
+	current.Occurrences++
+	current.UpdatedAt = execution.ExecutedAt
+	if current.Status == scheduleStatusActive {
+		current.planNextRun(scheduleToday())
+	}
+}
+
+// createSchedule stores a one-off or recurring transfer schedule
+// POST /api/dwolla/schedule
+func createSchedule(c *gin.Context) {
+	var reqBody struct {
+		Transfer       transferRequest `json:"transfer" binding:"required"`
+		Frequency      string          `json:"frequency" binding:"required"`
+		Interval       int             `json:"interval"`
+		StartDate      string          `json:"start_date"`
+		EndDate        string          `json:"end_date"`
+		MaxOccurrences int             `json:"max_occurrences"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reqBody.Transfer.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transfer.amount must be positive"})
+		return
+	}
+	if err := validateTransferMetadata(reqBody.Transfer.CorrelationID, reqBody.Transfer.Metadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Store canonical URLs so later executions do not depend on how the client referred to them
+	_, sourceURL, err := resolveResource(resourceFundingSources, reqBody.Transfer.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source: " + err.Error()})
+		return
+	}
+	_, destinationURL, err := resolveResource(resourceFundingSources, reqBody.Transfer.Destination)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination: " + err.Error()})
+		return
+	}
+	reqBody.Transfer.Source = sourceURL
+	reqBody.Transfer.Destination = destinationURL
+
+	startDate := reqBody.StartDate
+	if startDate == "" {
+		startDate = scheduleToday().Format(scheduleDateLayout)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	schedule := &transferSchedule{
+		ID:             newScheduleID(),
+		Transfer:       reqBody.Transfer,
+		Frequency:      reqBody.Frequency,
+		Interval:       reqBody.Interval,
+		StartDate:      startDate,
+		EndDate:        reqBody.EndDate,
+		MaxOccurrences: reqBody.MaxOccurrences,
+		Status:         scheduleStatusActive,
+		Executions:     []scheduleExecution{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := validateScheduleRule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if start, _ := time.Parse(scheduleDateLayout, schedule.StartDate); start.Before(scheduleToday()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be in the past"})
+		return
+	}
+
+	schedule.planNextRun(scheduleToday())
+
+	scheduleMutex.Lock()
+	transferSchedules[schedule.ID] = schedule
+	scheduleMutex.Unlock()
+
+	fmt.Printf("🗓 Created %s schedule %s, next run %s\n", schedule.Frequency, schedule.ID, schedule.NextRunDate)
+
+	created, _ := getScheduleCopy(schedule.ID)
+	c.JSON(http.StatusOK, created)
+}
+
+// listSchedules lists transfer schedules, optionally filtered by ?status=
+// GET /api/dwolla/schedules
+func listSchedules(c *gin.Context) {
+	status := c.Query("status")
+
+	scheduleMutex.RLock()
+	schedules := []transferSchedule{}
+	for _, schedule := range transferSchedules {
+		if status != "" && schedule.Status != status {
+			continue
+		}
+		copied := *schedule
+		copied.Executions = nil
+		schedules = append(schedules, copied)
+	}
+	scheduleMutex.RUnlock()
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt < schedules[j].CreatedAt
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     len(schedules),
+		"schedules": schedules,
+	})
+}
+
+// getSchedule returns a schedule with its execution history
+// GET /api/dwolla/schedule/:id
+func getSchedule(c *gin.Context) {
+	schedule, ok := getScheduleCopy(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found: " + c.Param("id")})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// updateSchedule pauses, resumes or changes the amount and limits of a schedule
+// POST /api/dwolla/schedule/:id
+func updateSchedule(c *gin.Context) {
+	var reqBody struct {
+		Status         string   `json:"status"` // "active" or "paused"
+		Amount         *float64 `json:"amount"`
+		EndDate        *string  `json:"end_date"`
+		MaxOccurrences *int     `json:"max_occurrences"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reqBody.Status != "" && reqBody.Status != scheduleStatusActive && reqBody.Status != scheduleStatusPaused {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'active' or 'paused'"})
+		return
+	}
+	if reqBody.Amount != nil && *reqBody.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	scheduleID := c.Param("id")
+	scheduleMutex.Lock()
+	schedule, ok := transferSchedules[scheduleID]
+	if !ok {
+		scheduleMutex.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found: " + scheduleID})
+		return
+	}
+	if schedule.Status == scheduleStatusCompleted || schedule.Status == scheduleStatusCancelled {
+		scheduleMutex.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "schedule is " + schedule.Status})
+		return
+	}
+
+	updated := *schedule
+	if reqBody.Amount != nil {
+		updated.Transfer.Amount = *reqBody.Amount
+	}
+	if reqBody.EndDate != nil {
+		updated.EndDate = *reqBody.EndDate
+	}
+	if reqBody.MaxOccurrences != nil {
+		updated.MaxOccurrences = *reqBody.MaxOccurrences
+	}
+	if reqBody.Status != "" {
+		updated.Status = reqBody.Status
+	}
+	if err := validateScheduleRule(&updated); err != nil {
+		scheduleMutex.Unlock()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resuming runs the latest missed occurrence right away and skips any earlier ones
+	updated.planNextRun(scheduleToday())
+	updated.UpdatedAt = time.Now().Format(time.RFC3339)
+	*schedule = updated
+	scheduleMutex.Unlock()
+
+	result, _ := getScheduleCopy(scheduleID)
+	c.JSON(http.StatusOK, result)
+}
+
+// cancelSchedule stops a schedule and keeps its execution history
+// DELETE /api/dwolla/schedule/:id
+func cancelSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	scheduleMutex.Lock()
+	schedule, ok := transferSchedules[scheduleID]
+	if !ok {
+		scheduleMutex.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found: " + scheduleID})
+		return
+	}
+	if schedule.Status == scheduleStatusCompleted || schedule.Status == scheduleStatusCancelled {
+		scheduleMutex.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "schedule is already " + schedule.Status})
+		return
+	}
+	schedule.Status = scheduleStatusCancelled
+	schedule.NextRunDate = ""
+	schedule.UpdatedAt = time.Now().Format(time.RFC3339)
+	scheduleMutex.Unlock()
+
+	fmt.Printf("🗓 Cancelled schedule %s\n", scheduleID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule_id": scheduleID,
+		"status":      scheduleStatusCancelled,
+	})
+}
+
+// getScheduleExecutions returns the execution history of a schedule
+// GET /api/dwolla/schedule/:id/executions
+func getScheduleExecutions(c *gin.Context) {
+	schedule, ok := getScheduleCopy(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found: " + c.Param("id")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule_id":   schedule.ID,
+		"status":        schedule.Status,
+		"occurrences":   schedule.Occurrences,
+		"next_run_date": schedule.NextRunDate,
+		"executions":    schedule.Executions,
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOccurrenceDate(t *testing.T) {
+	tests := []struct {
+		frequency string
+		interval  int
+		start     string
+		n         int
+		want      string
+	}{
+		{scheduleFrequencyOnce, 1, "2025-03-10", 0, "2025-03-10"},
+		{scheduleFrequencyDaily, 1, "2025-03-10", 3, "2025-03-13"},
+		{scheduleFrequencyDaily, 3, "2025-03-10", 2, "2025-03-16"},
+		{scheduleFrequencyWeekly, 2, "2025-03-10", 1, "2025-03-24"},
+		{scheduleFrequencyMonthly, 1, "2025-01-31", 1, "2025-02-28"}, // clamped to the end of February
+		{scheduleFrequencyMonthly, 1, "2025-01-31", 2, "2025-03-31"}, // back on the original day
+		{scheduleFrequencyMonthly, 1, "2024-01-31", 1, "2024-02-29"}, // leap year
+		{scheduleFrequencyMonthly, 3, "2025-11-30", 1, "2026-02-28"},
+	}
+
+	for _, tt := range tests {
+		s := &transferSchedule{Frequency: tt.frequency, Interval: tt.interval, StartDate: tt.start}
+		if got := s.occurrenceDate(tt.n).Format(scheduleDateLayout); got != tt.want {
+			t.Errorf("%s every %d from %s: occurrence %d = %s, want %s", tt.frequency, tt.interval, tt.start, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPlanNextRun(t *testing.T) {
+	tests := []struct {
+		name           string
+		schedule       transferSchedule
+		today          string
+		wantNext       string
+		wantStatus     string
+		wantOccurrence int
+		wantSkipped    int
+	}{
+		{
+			name:           "weekend occurrence moves to Monday",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyWeekly, Interval: 1, StartDate: "2025-03-08"},
+			today:          "2025-03-01",
+			wantNext:       "2025-03-10",
+			wantStatus:     scheduleStatusActive,
+			wantOccurrence: 0,
+		},
+		{
+			name:           "one-off completes after running",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyOnce, Interval: 1, StartDate: "2025-03-10", Occurrences: 1},
+			today:          "2025-03-10",
+			wantStatus:     scheduleStatusCompleted,
+			wantOccurrence: 1,
+		},
+		{
+			// Saturday the 29th and Sunday the 30th roll onto Monday the 31st and are not missed
+			name:           "resumed after 30 days skips up to today's business day",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-03-01"},
+			today:          "2025-03-31",
+			wantNext:       "2025-03-31",
+			wantStatus:     scheduleStatusActive,
+			wantOccurrence: 28,
+			wantSkipped:    28,
+		},
+		{
+			name:           "catch-up stops at max occurrences",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-03-03", MaxOccurrences: 3},
+			today:          "2025-03-31",
+			wantNext:       "2025-03-05",
+			wantStatus:     scheduleStatusActive,
+			wantOccurrence: 2,
+			wantSkipped:    2,
+		},
+		{
+			name:           "catch-up stops at the end date",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyWeekly, Interval: 1, StartDate: "2025-03-03", EndDate: "2025-03-17"},
+			today:          "2025-04-30",
+			wantNext:       "2025-03-17",
+			wantStatus:     scheduleStatusActive,
+			wantOccurrence: 2,
+			wantSkipped:    2,
+		},
+		{
+			name:           "end date reached",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyMonthly, Interval: 1, StartDate: "2025-01-15", EndDate: "2025-02-01", Occurrences: 1},
+			today:          "2025-01-15",
+			wantStatus:     scheduleStatusCompleted,
+			wantOccurrence: 1,
+		},
+		{
+			name:           "paused schedules skip nothing",
+			schedule:       transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-03-03", Status: scheduleStatusPaused},
+			today:          "2025-03-31",
+			wantNext:       "2025-03-03",
+			wantStatus:     scheduleStatusPaused,
+			wantOccurrence: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		s := tt.schedule
+		if s.Status == "" {
+			s.Status = scheduleStatusActive
+		}
+		s.planNextRun(day(t, tt.today))
+
+		skipped := 0
+		for _, execution := range s.Executions {
+			if execution.Status == "skipped" {
+				skipped++
+			}
+		}
+		if s.NextRunDate != tt.wantNext || s.Status != tt.wantStatus || s.Occurrences != tt.wantOccurrence || skipped != tt.wantSkipped {
+			t.Errorf("%s: next %q, status %s, occurrences %d, skipped %d; want %q, %s, %d, %d",
+				tt.name, s.NextRunDate, s.Status, s.Occurrences, skipped,
+				tt.wantNext, tt.wantStatus, tt.wantOccurrence, tt.wantSkipped)
+		}
+	}
+}
+
+func TestScheduleRunsRolledOccurrences(t *testing.T) {
+	tests := []struct {
+		name        string
+		schedule    transferSchedule
+		today       string
+		wantRuns    int
+		wantSkipped int
+		wantNext    string
+	}{
+		{
+			name:     "daily schedule runs Saturday, Sunday and Monday on Monday",
+			schedule: transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-03-07", Occurrences: 1},
+			today:    "2025-03-10",
+			wantRuns: 3,
+			wantNext: "2025-03-11",
+		},
+		{
+			// Memorial Day: Saturday, Sunday and the holiday all run on Tuesday
+			name:     "daily schedule around a Monday holiday",
+			schedule: transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-05-23", Occurrences: 1},
+			today:    "2025-05-27",
+			wantRuns: 4,
+			wantNext: "2025-05-28",
+		},
+		{
+			name:        "outage over the weekend skips to today",
+			schedule:    transferSchedule{Frequency: scheduleFrequencyDaily, Interval: 1, StartDate: "2025-03-07", Occurrences: 1},
+			today:       "2025-03-11",
+			wantRuns:    1,
+			wantSkipped: 3,
+			wantNext:    "2025-03-12",
+		},
+		{
+			name:     "weekly schedule on Saturday runs once on Monday",
+			schedule: transferSchedule{Frequency: scheduleFrequencyWeekly, Interval: 1, StartDate: "2025-03-08"},
+			today:    "2025-03-10",
+			wantRuns: 1,
+			wantNext: "2025-03-17",
+		},
+	}
+
+	for _, tt := range tests {
+		s := tt.schedule
+		s.Status = scheduleStatusActive
+		today := day(t, tt.today)
+
+		// Run every occurrence due today, as the schedule worker does
+		s.planNextRun(today)
+		runs := 0
+		for s.Status == scheduleStatusActive && s.NextRunDate == tt.today && runs < 10 {
+			runs++
+			s.Occurrences++
+			s.planNextRun(today)
+		}
+
+		skipped := 0
+		for _, execution := range s.Executions {
+			if execution.Status == "skipped" {
+				skipped++
+			}
+		}
+		if runs != tt.wantRuns || skipped != tt.wantSkipped || s.NextRunDate != tt.wantNext {
+			t.Errorf("%s: runs %d, skipped %d, next %q; want %d, %d, %q",
+				tt.name, runs, skipped, s.NextRunDate, tt.wantRuns, tt.wantSkipped, tt.wantNext)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"strings"
@@ -25,6 +26,10 @@ var (
 	DWOLLA_ENV            = ""
 	DWOLLA_BASE_URL       = ""
 	PLAID_API_URL         = ""
+	PLAID_CLIENT_ID       = ""
+	PLAID_SECRET          = ""
+	PLAID_BASE_URL        = ""
+	BANK_LINK_PROVIDER    = ""
 	APP_PORT              = ""
 	DWOLLA_WEBHOOK_SECRET = ""
 	WEBHOOK_BASE_URL      = ""
@@ -37,7 +42,9 @@ var (
 	webhookMutex  sync.RWMutex
 )
 
-func init() {
+// setup loads the configuration, obtains a Dwolla token and starts the background workers.
+// It runs from main rather than init so tests can load the package without credentials.
+func setup() {
 	// Load env vars from .env file
 	err := godotenv.Load()
 	if err != nil {
@@ -50,6 +57,10 @@ func init() {
 	DWOLLA_ENV = os.Getenv("DWOLLA_ENV")
 	DWOLLA_BASE_URL = os.Getenv("DWOLLA_BASE_URL")
 	PLAID_API_URL = os.Getenv("PLAID_API_URL")
+	PLAID_CLIENT_ID = os.Getenv("PLAID_CLIENT_ID")
+	PLAID_SECRET = os.Getenv("PLAID_SECRET")
+	PLAID_BASE_URL = os.Getenv("PLAID_BASE_URL")
+	BANK_LINK_PROVIDER = os.Getenv("BANK_LINK_PROVIDER")
 	APP_PORT = os.Getenv("APP_PORT")
 	DWOLLA_WEBHOOK_SECRET = os.Getenv("DWOLLA_WEBHOOK_SECRET")
 	WEBHOOK_BASE_URL = os.Getenv("WEBHOOK_BASE_URL")
@@ -64,6 +75,12 @@ func init() {
 	if PLAID_API_URL == "" {
 		PLAID_API_URL = "http://localhost:8000"
 	}
+	if PLAID_BASE_URL == "" {
+		PLAID_BASE_URL = "https://sandbox.plaid.com"
+	}
+	if BANK_LINK_PROVIDER == "" {
+		BANK_LINK_PROVIDER = "plaid"
+	}
 	if APP_PORT == "" {
 		APP_PORT = "8001"
 	}
@@ -76,6 +93,14 @@ func init() {
 	fmt.Printf("Dwolla environment: %s\n", DWOLLA_ENV)
 	fmt.Printf("Dwolla base URL: %s\n", DWOLLA_BASE_URL)
 	fmt.Printf("Plaid API URL: %s\n", PLAID_API_URL)
+	fmt.Printf("Plaid Link base URL: %s\n", PLAID_BASE_URL)
+	if _, ok := bankLinkProviders[BANK_LINK_PROVIDER]; !ok {
+		log.Fatalf("Error: BANK_LINK_PROVIDER must be 'plaid' or 'exchange', got %q", BANK_LINK_PROVIDER)
+	}
+	fmt.Printf("Default bank-linking provider: %s\n", BANK_LINK_PROVIDER)
+	if PLAID_CLIENT_ID == "" || PLAID_SECRET == "" {
+		fmt.Println("⚠ Warning: PLAID_CLIENT_ID or PLAID_SECRET not set, Plaid Link endpoints are disabled")
+	}
 
 	// Get Dwolla access token
 	if err := refreshDwollaToken(); err != nil {
@@ -85,6 +110,12 @@ func init() {
 
 	// Start background token refresh worker
 	go tokenRefreshWorker()
+
+	// Start background worker for scheduled transfers
+	go scheduleWorker()
+
+	// Start background worker for retries of returned transfers
+	go retryWorker()
 }
 
 // tokenRefreshWorker automatically refreshes the token before expiration
@@ -110,6 +141,8 @@ func tokenRefreshWorker() {
 }
 
 func main() {
+	setup()
+
 	r := gin.Default()
 
 	// Health check endpoint
@@ -124,10 +157,92 @@ func main() {
 
 	// Dwolla endpoints
 	r.GET("/api/dwolla/accounts", getAccounts)
+	r.POST("/api/dwolla/accounts/transfer", createAccountTransfer)
 	r.POST("/api/dwolla/customer", createCustomer)
 	r.POST("/api/dwolla/funding-source", createFundingSource)
 	r.POST("/api/dwolla/transfer", createTransfer)
 	r.GET("/api/dwolla/transfer/:id", getTransfer)
+	r.POST("/api/dwolla/transfer/:id/cancel", cancelTransfer)
+	r.GET("/api/dwolla/transfer/:id/failure", getTransferFailure)
+	r.POST("/api/dwolla/transfer/:id/refund", refundTransfer)
+	r.GET("/api/dwolla/customer/:id/transfers", listCustomerTransfers)
+	r.GET("/api/dwolla/transfers", searchLocalTransfers)
+	r.GET("/api/dwolla/fees/report", getFeeReport)
+	r.GET("/api/dwolla/return-codes", listReturnCodes)
+	r.GET("/api/dwolla/return-codes/:code", getReturnCode)
+	r.GET("/api/dwolla/retry-policies", listRetryPolicies)
+	r.POST("/api/dwolla/retry-policy", setRetryPolicy)
+	r.DELETE("/api/dwolla/retry-policy/:code", deleteRetryPolicy)
+	r.GET("/api/dwolla/retries", listTransferRetries)
+
+	// Scheduled transfer endpoints
+	r.POST("/api/dwolla/schedule", createSchedule)
+	r.GET("/api/dwolla/schedules", listSchedules)
+	r.GET("/api/dwolla/schedule/:id", getSchedule)
+	r.POST("/api/dwolla/schedule/:id", updateSchedule)
+	r.DELETE("/api/dwolla/schedule/:id", cancelSchedule)
+	r.GET("/api/dwolla/schedule/:id/executions", getScheduleExecutions)
+
+	// Mass payment endpoints
+	r.POST("/api/dwolla/mass-payment", createMassPayment)
+	r.GET("/api/dwolla/mass-payment/:id", getMassPayment)
+	r.GET("/api/dwolla/mass-payment/:id/report", getMassPaymentReport)
+
+	// Funding source endpoints
+	r.GET("/api/dwolla/customer/:id/funding-sources", listCustomerFundingSources)
+	r.GET("/api/dwolla/customer/:id/balance", getCustomerBalance)
+	r.POST("/api/dwolla/customer/:id/balance/transfer", createCustomerBalanceTransfer)
+	r.GET("/api/dwolla/funding-source/:id", getFundingSource)
+	r.GET("/api/dwolla/funding-source/:id/balance", getFundingSourceBalance)
+	r.POST("/api/dwolla/funding-source/:id", updateFundingSource)
+	r.DELETE("/api/dwolla/funding-source/:id", removeFundingSource)
+	r.POST("/api/dwolla/funding-source/manual", createManualFundingSource)
+	r.POST("/api/dwolla/funding-source/:id/micro-deposits", startMicroDeposits)
+	r.GET("/api/dwolla/funding-source/:id/micro-deposits", getMicroDeposits)
+	r.POST("/api/dwolla/funding-source/:id/micro-deposits/verify", verifyMicroDeposits)
+
+	// Ledger endpoints
+	r.GET("/api/dwolla/ledger/accounts", getLedgerAccounts)
+	r.GET("/api/dwolla/ledger/customer/:id", getLedgerCustomer)
+	r.GET("/api/dwolla/ledger/platform", getLedgerPlatform)
+	r.GET("/api/dwolla/ledger/entries", listLedgerEntries)
+
+	// Customer management endpoints
+	r.GET("/api/dwolla/customers", listCustomers)
+	r.GET("/api/dwolla/customer/:id", getCustomer)
+	r.POST("/api/dwolla/customer/:id", updateCustomer)
+	r.POST("/api/dwolla/customer/:id/retry-verification", retryCustomerVerification)
+	r.POST("/api/dwolla/customer/:id/deactivate", deactivateCustomer)
+	r.POST("/api/dwolla/customer/:id/suspend", suspendCustomer)
+	r.POST("/api/dwolla/customer/:id/reactivate", reactivateCustomer)
+	r.GET("/api/dwolla/customer/:id/audit-log", getCustomerAuditLog)
+
+	// Business customer endpoints
+	r.POST("/api/dwolla/business-customer", createBusinessCustomer)
+	r.GET("/api/dwolla/business-classifications", listBusinessClassifications)
+	r.POST("/api/dwolla/customer/:id/beneficial-owners", addBeneficialOwner)
+	r.GET("/api/dwolla/customer/:id/beneficial-owners", listBeneficialOwners)
+	r.POST("/api/dwolla/beneficial-owner/:id", updateBeneficialOwner)
+	r.DELETE("/api/dwolla/beneficial-owner/:id", removeBeneficialOwner)
+	r.GET("/api/dwolla/customer/:id/beneficial-ownership", getBeneficialOwnership)
+	r.POST("/api/dwolla/customer/:id/beneficial-ownership/certify", certifyBeneficialOwnership)
+
+	// Verification document endpoints
+	r.POST("/api/dwolla/customer/:id/documents", uploadCustomerDocument)
+	r.GET("/api/dwolla/customer/:id/documents", listCustomerDocuments)
+	r.POST("/api/dwolla/beneficial-owner/:id/documents", uploadOwnerDocument)
+	r.GET("/api/dwolla/beneficial-owner/:id/documents", listOwnerDocuments)
+
+	// Plaid Link endpoints
+	r.POST("/api/plaid/link-token", createPlaidLinkToken)
+	r.POST("/api/plaid/exchange", exchangePlaidPublicToken)
+	r.GET("/api/plaid/item/:item_id/accounts", listPlaidAccounts)
+	r.POST("/api/plaid/processor-token", createProcessorToken)
+
+	// Open-banking exchange endpoints
+	r.GET("/api/dwolla/exchange-partners", listExchangePartners)
+	r.POST("/api/dwolla/customer/:id/exchanges", createExchange)
+	r.GET("/api/dwolla/customer/:id/exchanges", listExchanges)
 
 	// Webhook endpoints
 	r.POST("/api/dwolla/webhook-subscription", createWebhookSubscription)
@@ -138,6 +253,7 @@ func main() {
 
 	// Sandbox simulation endpoints
 	r.POST("/api/dwolla/simulate-transfer", simulateTransfer)
+	r.POST("/api/dwolla/simulate-transfers", simulatePendingTransfers)
 
 	fmt.Printf("Dwolla Transfer Demo server starting on port %s...\n", APP_PORT)
 	err := r.Run(":" + APP_PORT)
@@ -216,13 +332,68 @@ func makeDwollaRequest(method, url string, body interface{}) (map[string]interfa
 
 // makeDwollaRequestWithRetry makes an authenticated request with optional retry
 func makeDwollaRequestWithRetry(method, url string, body interface{}, allowRetry bool) (map[string]interface{}, int, error) {
-	var reqBody io.Reader
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return sendDwollaRequest(method, url, "application/vnd.dwolla.v1.hal+json", nil, jsonData, allowRetry)
+}
+
+// makeDwollaIdempotentRequest makes an authenticated request with an Idempotency-Key header so
+// Dwolla returns the original resource instead of creating a duplicate when the request is repeated
+func makeDwollaIdempotentRequest(method, url string, body interface{}, idempotencyKey string) (map[string]interface{}, int, error) {
+	if idempotencyKey == "" {
+		return makeDwollaRequest(method, url, body)
+	}
+
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, 0, err
 		}
-		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	headers := map[string]string{"Idempotency-Key": idempotencyKey}
+	return sendDwollaRequest(method, url, "application/vnd.dwolla.v1.hal+json", headers, jsonData, true)
+}
+
+// makeDwollaMultipartRequest uploads a file to Dwolla as multipart/form-data with automatic retry on 401
+func makeDwollaMultipartRequest(url string, fields map[string]string, fileName string, file []byte) (map[string]interface{}, int, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := part.Write(file); err != nil {
+		return nil, 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	return sendDwollaRequest("POST", url, writer.FormDataContentType(), nil, buf.Bytes(), true)
+}
+
+// sendDwollaRequest sends an already encoded body to Dwolla and parses the HAL response
+func sendDwollaRequest(method, url, contentType string, headers map[string]string, body []byte, allowRetry bool) (map[string]interface{}, int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
 	}
 
 	req, err := http.NewRequest(method, url, reqBody)
@@ -236,8 +407,11 @@ func makeDwollaRequestWithRetry(method, url string, body interface{}, allowRetry
 	tokenMutex.RUnlock()
 
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/vnd.dwolla.v1.hal+json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/vnd.dwolla.v1.hal+json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
@@ -258,7 +432,7 @@ func makeDwollaRequestWithRetry(method, url string, body interface{}, allowRetry
 			return nil, resp.StatusCode, fmt.Errorf("token refresh failed: %w", err)
 		}
 		// Retry once with new token (allowRetry = false to prevent infinite loop)
-		return makeDwollaRequestWithRetry(method, url, body, false)
+		return sendDwollaRequest(method, url, contentType, headers, body, false)
 	}
 
 	var result map[string]interface{}
@@ -281,259 +455,6 @@ func makeDwollaRequestWithRetry(method, url string, body interface{}, allowRetry
 	return result, resp.StatusCode, nil
 }
 
-// getProcessorToken calls Plaid API to get a processor token
-func getProcessorToken() (string, error) {
-	url := PLAID_API_URL + "/api/sandbox/processor_token"
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte("{}")))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get processor token, status: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		ProcessorToken string `json:"processor_token"`
-		AccountID      string `json:"account_id"`
-		ItemID         string `json:"item_id"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-
-	fmt.Printf("Got processor_token from Plaid: %s\n", result.ProcessorToken)
-	return result.ProcessorToken, nil
-}
-
-// getAccounts gets Dwolla root/master account information
-// GET /api/dwolla/accounts
-func getAccounts(c *gin.Context) {
-	url := DWOLLA_BASE_URL
-	result, status, err := makeDwollaRequest("GET", url, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusOK {
-		c.JSON(status, gin.H{"error": "Failed to get accounts", "details": result})
-		return
-	}
-
-	// Extract account URL from _links
-	links, ok := result["_links"].(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response format"})
-		return
-	}
-
-	account, ok := links["account"].(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Account link not found"})
-		return
-	}
-
-	accountHref, ok := account["href"].(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Account href not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"account_url": accountHref,
-		"_links":      links,
-	})
-}
-
-// createCustomer creates a Dwolla customer
-// POST /api/dwolla/customer
-func createCustomer(c *gin.Context) {
-	var reqBody struct {
-		FirstName string `json:"firstName" binding:"required"`
-		LastName  string `json:"lastName" binding:"required"`
-		Email     string `json:"email" binding:"required"`
-	}
-
-	if err := c.BindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Create customer payload
-	payload := map[string]interface{}{
-		"firstName": reqBody.FirstName,
-		"lastName":  reqBody.LastName,
-		"email":     reqBody.Email,
-	}
-
-	url := DWOLLA_BASE_URL + "/customers"
-	result, status, err := makeDwollaRequest("POST", url, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusCreated {
-		c.JSON(status, gin.H{"error": "Failed to create customer", "details": result})
-		return
-	}
-
-	customerURL := result["location"].(string)
-	fmt.Printf("Created customer: %s\n", customerURL)
-
-	c.JSON(http.StatusOK, gin.H{
-		"customer_url": customerURL,
-		"status":       "created",
-	})
-}
-
-// createFundingSource adds a bank account as a funding source
-// POST /api/dwolla/funding-source
-func createFundingSource(c *gin.Context) {
-	var reqBody struct {
-		CustomerURL string `json:"customer_url" binding:"required"`
-		Name        string `json:"name"`
-	}
-
-	if err := c.BindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Get processor token from Plaid
-	processorToken, err := getProcessorToken()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to get processor token from Plaid",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	// Set default name if not provided
-	name := reqBody.Name
-	if name == "" {
-		name = "Bank Account"
-	}
-
-	// Create funding source payload
-	payload := map[string]interface{}{
-		"plaidToken": processorToken,
-		"name":       name,
-	}
-
-	url := reqBody.CustomerURL + "/funding-sources"
-	result, status, err := makeDwollaRequest("POST", url, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusCreated {
-		c.JSON(status, gin.H{"error": "Failed to create funding source", "details": result})
-		return
-	}
-
-	fundingSourceURL := result["location"].(string)
-	fmt.Printf("Created funding source: %s\n", fundingSourceURL)
-
-	c.JSON(http.StatusOK, gin.H{
-		"funding_source_url": fundingSourceURL,
-		"status":             "created",
-	})
-}
-
-// createTransfer initiates a transfer
-// POST /api/dwolla/transfer
-func createTransfer(c *gin.Context) {
-	var reqBody struct {
-		Source      string  `json:"source" binding:"required"`
-		Destination string  `json:"destination" binding:"required"`
-		Amount      float64 `json:"amount" binding:"required"`
-		Currency    string  `json:"currency"`
-	}
-
-	if err := c.BindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Set default currency
-	currency := reqBody.Currency
-	if currency == "" {
-		currency = "USD"
-	}
-
-	// Create transfer payload
-	payload := map[string]interface{}{
-		"_links": map[string]interface{}{
-			"source": map[string]string{
-				"href": reqBody.Source,
-			},
-			"destination": map[string]string{
-				"href": reqBody.Destination,
-			},
-		},
-		"amount": map[string]interface{}{
-			"currency": currency,
-			"value":    fmt.Sprintf("%.2f", reqBody.Amount),
-		},
-	}
-
-	url := DWOLLA_BASE_URL + "/transfers"
-	result, status, err := makeDwollaRequest("POST", url, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusCreated {
-		c.JSON(status, gin.H{"error": "Failed to create transfer", "details": result})
-		return
-	}
-
-	transferURL := result["location"].(string)
-	fmt.Printf("Created transfer: %s\n", transferURL)
-
-	c.JSON(http.StatusOK, gin.H{
-		"transfer_url": transferURL,
-		"status":       "created",
-	})
-}
-
-// getTransfer retrieves transfer details
-// GET /api/dwolla/transfer/:id
-func getTransfer(c *gin.Context) {
-	transferID := c.Param("id")
-
-	url := DWOLLA_BASE_URL + "/transfers/" + transferID
-	result, status, err := makeDwollaRequest("GET", url, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusOK {
-		c.JSON(status, gin.H{"error": "Failed to get transfer", "details": result})
-		return
-	}
-
-	c.JSON(http.StatusOK, result)
-}
-
 // createWebhookSubscription creates or updates a webhook subscription
 // POST /api/dwolla/webhook-subscription
 func createWebhookSubscription(c *gin.Context) {
@@ -711,6 +632,9 @@ func handleWebhook(c *gin.Context) {
 		}
 	}
 
+	// Keep local transfer state in sync
+	updateTransferFromWebhook(topic, webhook)
+
 	// Handle specific event types
 	switch topic {
 	case "transfer_completed":
@@ -725,8 +649,13 @@ func handleWebhook(c *gin.Context) {
 		fmt.Println("🏦 Funding source added")
 	case "customer_funding_source_verified":
 		fmt.Println("✓ Funding source verified")
+		handleMicroDepositWebhook(topic, webhook)
 	default:
-		fmt.Printf("ℹ Event: %s\n", topic)
+		if !handleDocumentWebhook(topic, webhook) &&
+			!handleMicroDepositWebhook(topic, webhook) &&
+			!handleMassPaymentWebhook(topic, webhook) {
+			fmt.Printf("ℹ Event: %s\n", topic)
+		}
 	}
 
 	// Print full webhook payload for debugging
@@ -743,76 +672,6 @@ func handleWebhook(c *gin.Context) {
 	})
 }
 
-// simulateTransfer simulates transfer processing in sandbox environment
-// POST /api/dwolla/simulate-transfer
-func simulateTransfer(c *gin.Context) {
-	var reqBody struct {
-		TransferURL string `json:"transfer_url" binding:"required"`
-		Action      string `json:"action"` // "process" (complete) or "fail"
-	}
-
-	if err := c.BindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Set default action to process (complete the transfer)
-	action := reqBody.Action
-	if action == "" {
-		action = "process"
-	}
-
-	// Validate action
-	if action != "process" && action != "fail" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be 'process' or 'fail'"})
-		return
-	}
-
-	// Create simulation payload
-	payload := map[string]interface{}{
-		"_links": map[string]interface{}{
-			"transfer": map[string]string{
-				"href": reqBody.TransferURL,
-			},
-		},
-	}
-
-	// For process action, simulate processing
-	if action == "process" {
-		// Process the transfer to completion
-	} else if action == "fail" {
-		// Add failure code for failed transfers
-		payload["failureCode"] = "R01" // Insufficient Funds
-	}
-
-	url := DWOLLA_BASE_URL + "/sandbox-simulations"
-	result, status, err := makeDwollaRequest("POST", url, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if status != http.StatusCreated && status != http.StatusOK {
-		c.JSON(status, gin.H{"error": "Failed to simulate transfer", "details": result})
-		return
-	}
-
-	actionMsg := "completed"
-	if action == "fail" {
-		actionMsg = "failed"
-	}
-
-	fmt.Printf("✓ Simulated transfer %s: %s\n", actionMsg, reqBody.TransferURL)
-	fmt.Printf("  💡 Check webhook logs for transfer_%s event\n", actionMsg)
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":       "simulated",
-		"action":       action,
-		"transfer_url": reqBody.TransferURL,
-		"message":      fmt.Sprintf("Transfer simulation initiated. Webhook should trigger transfer_%s event.", actionMsg),
-	})
-}
-
 // getWebhookEvents returns the list of received webhook events
 // GET /api/dwolla/webhook-events
 func getWebhookEvents(c *gin.Context) {
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transfer legs that can be simulated. A bank-to-bank transfer is funded by a
+// bank transfer from the source and paid out by a bank transfer to the destination.
+const (
+	simulationLegSource      = "source"
+	simulationLegDestination = "destination"
+	simulationLegBoth        = "both"
+)
+
+// Webhooks are waited for at most this long per simulation request
+const maxSimulationWaitSeconds = 30
+
+// simulationRequest selects what to simulate on a transfer
+type simulationRequest struct {
+	Action      string `json:"action"`       // "process" (complete) or "fail"
+	FailureCode string `json:"failure_code"` // any ACH return code, default R01
+	Leg         string `json:"leg"`          // "source", "destination" or "both", default "source"
+	WaitSeconds int    `json:"wait_seconds"` // how long to wait for the resulting webhooks
+}
+
+// simulatedLeg is the outcome of simulating one leg of a transfer
+type simulatedLeg struct {
+	Leg         string `json:"leg"`
+	TransferURL string `json:"transfer_url"` // the bank transfer of the leg, or the transfer itself
+	Status      string `json:"status"`       // "simulated" or "error"
+	Error       string `json:"error,omitempty"`
+}
+
+// simulationResult is the outcome of simulating one transfer, with the webhooks it caused
+type simulationResult struct {
+	TransferID  string                   `json:"transfer_id"`
+	TransferURL string                   `json:"transfer_url"`
+	Legs        []simulatedLeg           `json:"legs"`
+	Webhooks    []map[string]interface{} `json:"webhooks"`
+}
+
+// validate applies defaults and checks the action, return code, leg and wait time
+func (r *simulationRequest) validate() error {
+	if r.Action == "" {
+		r.Action = "process"
+	}
+	if r.Action != "process" && r.Action != "fail" {
+		return fmt.Errorf("action must be 'process' or 'fail'")
+	}
+
+	if r.Action == "fail" {
+		if r.FailureCode == "" {
+			r.FailureCode = "R01"
+		}
+		rc, ok := lookupReturnCode(r.FailureCode)
+		if !ok {
+			return fmt.Errorf("unknown return code: %s", r.FailureCode)
+		}
+		r.FailureCode = rc.Code
+	} else if r.FailureCode != "" {
+		return fmt.Errorf("failure_code is only allowed with action 'fail'")
+	}
+
+	switch r.Leg {
+	case "":
+		r.Leg = simulationLegSource
+	case simulationLegSource, simulationLegDestination, simulationLegBoth:
+	default:
+		return fmt.Errorf("leg must be 'source', 'destination' or 'both'")
+	}
+
+	if r.WaitSeconds < 0 || r.WaitSeconds > maxSimulationWaitSeconds {
+		return fmt.Errorf("wait_seconds must be between 0 and %d", maxSimulationWaitSeconds)
+	}
+	return nil
+}
+
+// simulationLegURLs finds the bank transfers behind the requested legs of a transfer.
+// Transfers without a bank leg on that side are simulated directly, and only once when
+// both legs come down to the same transfer.
+func simulationLegURLs(transferURL, leg string) (map[string]string, error) {
+	result, status, err := makeDwollaRequest("GET", transferURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to get transfer: %v", result)
+	}
+	links, _ := result["_links"].(map[string]interface{})
+
+	legs := map[string]string{}
+	if leg == simulationLegSource || leg == simulationLegBoth {
+		legs[simulationLegSource] = linkHref(links, "funding-transfer")
+	}
+	if leg == simulationLegDestination || leg == simulationLegBoth {
+		legs[simulationLegDestination] = linkHref(links, "funded-transfer")
+	}
+	for name, href := range legs {
+		if href == "" {
+			legs[name] = transferURL
+		}
+	}
+	if leg == simulationLegBoth && legs[simulationLegSource] == legs[simulationLegDestination] {
+		delete(legs, simulationLegDestination)
+	}
+	return legs, nil
+}
+
+// simulateLeg asks the Dwolla sandbox to process or fail one bank transfer
+func simulateLeg(legTransferURL, action, failureCode string) error {
+	payload := map[string]interface{}{
+		"_links": map[string]interface{}{
+			"transfer": map[string]string{
+				"href": legTransferURL,
+			},
+		},
+	}
+	if action == "fail" {
+		payload["failureCode"] = failureCode
+	}
+
+	url := DWOLLA_BASE_URL + "/sandbox-simulations"
+	result, status, err := makeDwollaRequest("POST", url, payload)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return fmt.Errorf("failed to simulate transfer: %v", result)
+	}
+	return nil
+}
+
+// runSimulation simulates the requested legs of one transfer
+func runSimulation(transferID, transferURL string, req simulationRequest) simulationResult {
+	result := simulationResult{
+		TransferID:  transferID,
+		TransferURL: transferURL,
+		Legs:        []simulatedLeg{},
+		Webhooks:    []map[string]interface{}{},
+	}
+
+	legs, err := simulationLegURLs(transferURL, req.Leg)
+	if err != nil {
+		result.Legs = append(result.Legs, simulatedLeg{Leg: req.Leg, TransferURL: transferURL, Status: "error", Error: err.Error()})
+		return result
+	}
+
+	for _, name := range []string{simulationLegSource, simulationLegDestination} {
+		legURL, ok := legs[name]
+		if !ok {
+			continue
+		}
+		leg := simulatedLeg{Leg: name, TransferURL: legURL, Status: "simulated"}
+		if err := simulateLeg(legURL, req.Action, req.FailureCode); err != nil {
+			leg.Status = "error"
+			leg.Error = err.Error()
+		}
+		result.Legs = append(result.Legs, leg)
+	}
+
+	if req.Action == "fail" {
+		fmt.Printf("✓ Simulated %s failure (%s) of transfer %s\n", req.Leg, req.FailureCode, transferURL)
+	} else {
+		fmt.Printf("✓ Simulated %s completion of transfer %s\n", req.Leg, transferURL)
+	}
+	return result
+}
+
+// seenWebhookIDs returns the IDs of the webhook events received so far
+func seenWebhookIDs() map[string]bool {
+	webhookMutex.RLock()
+	defer webhookMutex.RUnlock()
+	seen := map[string]bool{}
+	for _, event := range webhookEvents {
+		if id, ok := event["id"].(string); ok {
+			seen[id] = true
+		}
+	}
+	return seen
+}
+
+// correlateWebhooks attaches webhook events received after a simulation started to the
+// transfers whose main or leg transfers they are about. It waits up to wait for every
+// transfer to receive a terminal transfer event.
+func correlateWebhooks(results []simulationResult, seen map[string]bool, wait time.Duration) {
+	owner := map[string]int{}
+	for i, result := range results {
+		owner[result.TransferURL] = i
+		for _, leg := range result.Legs {
+			owner[leg.TransferURL] = i
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		for i := range results {
+			results[i].Webhooks = []map[string]interface{}{}
+		}
+		terminal := map[int]bool{}
+
+		webhookMutex.RLock()
+		for _, event := range webhookEvents {
+			id, _ := event["id"].(string)
+			if seen[id] {
+				continue
+			}
+			links, _ := event["_links"].(map[string]interface{})
+			i, ok := owner[linkHref(links, "resource")]
+			if !ok {
+				continue
+			}
+			results[i].Webhooks = append(results[i].Webhooks, event)
+			topic, _ := event["topic"].(string)
+			if transferStatusFromTopic(topic) != "" && linkHref(links, "resource") == results[i].TransferURL {
+				terminal[i] = true
+			}
+		}
+		webhookMutex.RUnlock()
+
+		if len(terminal) == len(results) || !time.Now().Before(deadline) {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// simulateTransfer simulates transfer processing in sandbox environment.
+// Accepts any ACH return code for failures and can target the source leg, the destination leg or both.
+// Only available against the Dwolla sandbox.
+// POST /api/dwolla/simulate-transfer
+func simulateTransfer(c *gin.Context) {
+	var reqBody struct {
+		TransferURL string `json:"transfer_url"` // transfer ID or URL
+		TransferID  string `json:"transfer_id"`
+		simulationRequest
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !strings.EqualFold(DWOLLA_ENV, "sandbox") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "simulation is only available in the sandbox environment"})
+		return
+	}
+
+	transferRef := reqBody.TransferURL
+	if transferRef == "" {
+		transferRef = reqBody.TransferID
+	}
+	transferID, transferURL, err := resolveResource(resourceTransfers, transferRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := reqBody.simulationRequest.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := seenWebhookIDs()
+	results := []simulationResult{runSimulation(transferID, transferURL, reqBody.simulationRequest)}
+	for _, leg := range results[0].Legs {
+		if leg.Status == "error" {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to simulate transfer", "details": results[0]})
+			return
+		}
+	}
+	correlateWebhooks(results, seen, time.Duration(reqBody.WaitSeconds)*time.Second)
+
+	actionMsg := "completed"
+	if reqBody.Action == "fail" {
+		actionMsg = "failed"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "simulated",
+		"action":       reqBody.Action,
+		"failure_code": reqBody.FailureCode,
+		"leg":          reqBody.Leg,
+		"transfer_id":  transferID,
+		"transfer_url": transferURL,
+		"legs":         results[0].Legs,
+		"webhooks":     results[0].Webhooks,
+		"message":      fmt.Sprintf("Transfer simulation initiated. Webhook should trigger transfer_%s event.", actionMsg),
+	})
+}
+
+// simulatePendingTransfers simulates every pending transfer created through this service.
+// Only available against the Dwolla sandbox.
+// POST /api/dwolla/simulate-transfers
+func simulatePendingTransfers(c *gin.Context) {
+	var reqBody simulationRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !strings.EqualFold(DWOLLA_ENV, "sandbox") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "batch simulation is only available in the sandbox environment"})
+		return
+	}
+	if err := reqBody.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferMutex.RLock()
+	var pending []transferRecord
+	for _, record := range transferRecords {
+		if record.Status == transferStatusPending {
+			pending = append(pending, *record)
+		}
+	}
+	transferMutex.RUnlock()
+
+	seen := seenWebhookIDs()
+	results := []simulationResult{}
+	failed := 0
+	for _, record := range pending {
+		result := runSimulation(record.ID, record.Href, reqBody)
+		for _, leg := range result.Legs {
+			if leg.Status == "error" {
+				failed++
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	correlateWebhooks(results, seen, time.Duration(reqBody.WaitSeconds)*time.Second)
+
+	c.JSON(http.StatusOK, gin.H{
+		"action":       reqBody.Action,
+		"failure_code": reqBody.FailureCode,
+		"leg":          reqBody.Leg,
+		"simulated":    len(results) - failed,
+		"errors":       failed,
+		"transfers":    results,
+	})
+}
@@ -181,7 +181,8 @@ FUNDING_RESPONSE=$(curl -s -X POST "${DWOLLA_URL}/api/dwolla/funding-source" \
   -H "Content-Type: application/json" \
   -d "{
     \"customer_url\": \"$CUSTOMER_URL\",
-    \"name\": \"Test Bank Account\"
+    \"name\": \"Test Bank Account\",
+    \"plaid_sandbox\": true
   }")
 
 if echo "$FUNDING_RESPONSE" | jq -e '.funding_source_url' > /dev/null 2>&1; then
@@ -220,7 +221,8 @@ RECEIVER_FUNDING_RESPONSE=$(curl -s -X POST "${DWOLLA_URL}/api/dwolla/funding-so
   -H "Content-Type: application/json" \
   -d "{
     \"customer_url\": \"$RECEIVER_URL\",
-    \"name\": \"Receiver Bank Account\"
+    \"name\": \"Receiver Bank Account\",
+    \"plaid_sandbox\": true
   }")
 
 if echo "$RECEIVER_FUNDING_RESPONSE" | jq -e '.funding_source_url' > /dev/null 2>&1; then
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Transfer clearing speeds and processing channels
+const (
+	clearingStandard      = "standard"
+	clearingNextAvailable = "next-available"
+	channelRealTime       = "real-time-payments"
+	maxAddendaLength      = 80
+)
+
+// transferOptions are the optional ACH and instant payment settings of a transfer
+type transferOptions struct {
+	Clearing struct {
+		Source      string `json:"source"`      // "standard"; Dwolla does not expedite debits
+		Destination string `json:"destination"` // "standard" or "next-available" (same-day ACH)
+	} `json:"clearing"`
+	Addenda struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	} `json:"addenda"`
+	ProcessingChannel string `json:"processingChannel"` // "" for ACH or "real-time-payments"
+}
+
+// validate checks the options against the source and destination funding source types
+func (o transferOptions) validate(source, destination fundingSource) error {
+	for _, leg := range []struct {
+		name          string
+		clearing      string
+		nextAvailable bool // whether the leg may use same-day clearing
+		addenda       string
+		fs            fundingSource
+	}{
+		{"source", o.Clearing.Source, false, o.Addenda.Source, source},
+		{"destination", o.Clearing.Destination, true, o.Addenda.Destination, destination},
+	} {
+		if leg.clearing != "" {
+			switch {
+			case leg.clearing == clearingStandard:
+			case leg.clearing == clearingNextAvailable && leg.nextAvailable:
+			case leg.nextAvailable:
+				return fmt.Errorf("clearing.%s must be 'standard' or 'next-available'", leg.name)
+			default:
+				return fmt.Errorf("clearing.%s must be 'standard'", leg.name)
+			}
+			if leg.fs.Type != "bank" {
+				return fmt.Errorf("clearing.%s only applies to bank funding sources, %s is a %s", leg.name, leg.name, leg.fs.Type)
+			}
+		}
+		if leg.addenda != "" {
+			if len(leg.addenda) > maxAddendaLength {
+				return fmt.Errorf("addenda.%s must be %d characters or fewer", leg.name, maxAddendaLength)
+			}
+			if leg.fs.Type != "bank" {
+				return fmt.Errorf("addenda.%s only applies to bank funding sources, %s is a %s", leg.name, leg.name, leg.fs.Type)
+			}
+		}
+	}
+
+	switch o.ProcessingChannel {
+	case "":
+	case channelRealTime:
+		if destination.Type != "bank" || !hasChannel(destination, channelRealTime) {
+			return fmt.Errorf("destination funding source does not support real-time payments")
+		}
+		if o.Clearing.Destination != "" || o.Addenda.Destination != "" {
+			return fmt.Errorf("clearing.destination and addenda.destination cannot be combined with real-time payments")
+		}
+	default:
+		return fmt.Errorf("processingChannel must be empty or 'real-time-payments'")
+	}
+
+	return nil
+}
+
+// apply adds the options to a Dwolla transfer payload
+func (o transferOptions) apply(payload map[string]interface{}) {
+	clearing := map[string]string{}
+	if o.Clearing.Source != "" {
+		clearing["source"] = o.Clearing.Source
+	}
+	if o.Clearing.Destination != "" {
+		clearing["destination"] = o.Clearing.Destination
+	}
+	if len(clearing) > 0 {
+		payload["clearing"] = clearing
+	}
+
+	achDetails := map[string]interface{}{}
+	if o.Addenda.Source != "" {
+		achDetails["source"] = map[string]interface{}{
+			"addenda": map[string]interface{}{"values": []string{o.Addenda.Source}},
+		}
+	}
+	if o.Addenda.Destination != "" {
+		achDetails["destination"] = map[string]interface{}{
+			"addenda": map[string]interface{}{"values": []string{o.Addenda.Destination}},
+		}
+	}
+	if len(achDetails) > 0 {
+		payload["achDetails"] = achDetails
+	}
+
+	if o.ProcessingChannel != "" {
+		payload["processingChannel"] = map[string]string{
+			"destination": o.ProcessingChannel,
+		}
+	}
+}
+
+// hasChannel reports whether a funding source supports a payment channel
+func hasChannel(fs fundingSource, channel string) bool {
+	for _, c := range fs.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestTransferOptionsClearing(t *testing.T) {
+	bank := fundingSource{Type: "bank"}
+	balance := fundingSource{Type: "balance"}
+
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		srcFS       fundingSource
+		dstFS       fundingSource
+		wantErr     bool
+	}{
+		{"no clearing", "", "", bank, bank, false},
+		{"standard both", clearingStandard, clearingStandard, bank, bank, false},
+		{"same-day destination", "", clearingNextAvailable, bank, bank, false},
+		{"same-day source", clearingNextAvailable, "", bank, bank, true},
+		{"unknown destination", "", "fast", bank, bank, true},
+		{"balance destination", "", clearingNextAvailable, bank, balance, true},
+		{"balance source", clearingStandard, "", balance, bank, true},
+	}
+
+	for _, tt := range tests {
+		var o transferOptions
+		o.Clearing.Source = tt.source
+		o.Clearing.Destination = tt.destination
+		err := o.validate(tt.srcFS, tt.dstFS)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
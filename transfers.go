@@ -0,0 +1,726 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dwolla transfer statuses
+const (
+	transferStatusPending   = "pending"
+	transferStatusProcessed = "processed"
+	transferStatusFailed    = "failed"
+	transferStatusCancelled = "cancelled"
+)
+
+// transferRecord is the local state of a transfer created through this service
+type transferRecord struct {
+	ID              string                `json:"id"`
+	Href            string                `json:"href"`
+	SourceURL       string                `json:"source_url"`
+	DestinationURL  string                `json:"destination_url"`
+	Amount          string                `json:"amount"`
+	Currency        string                `json:"currency"`
+	Status          string                `json:"status"`
+	CorrelationID   string                `json:"correlation_id,omitempty"`
+	Metadata        map[string]string     `json:"metadata,omitempty"`
+	Fees            []transferFee         `json:"fees,omitempty"`
+	Options         *transferOptions      `json:"options,omitempty"` // clearing, addenda and processing channel
+	Failure         *transferFailure      `json:"failure,omitempty"`
+	RetryOf         string                `json:"retry_of,omitempty"`          // original transfer this one retries
+	RetryAttempt    int                   `json:"retry_attempt,omitempty"`     // 1 for the first retry
+	RetryTransferID string                `json:"retry_transfer_id,omitempty"` // retry created after this transfer failed
+	RefundOf        string                `json:"refund_of,omitempty"`         // transfer this one refunds
+	RefundReason    string                `json:"refund_reason,omitempty"`
+	Instant         bool                  `json:"instant,omitempty"` // balance-to-balance, processed on creation
+	History         []transferStatusEvent `json:"history"`
+	CreatedAt       string                `json:"created_at"`
+	UpdatedAt       string                `json:"updated_at"`
+}
+
+// transferStatusEvent is one status change of a transfer, echoing its reconciliation identifiers
+type transferStatusEvent struct {
+	Status        string            `json:"status"`
+	Source        string            `json:"source"` // "api" or the webhook topic
+	EventID       string            `json:"event_id,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Timestamp     string            `json:"timestamp"`
+}
+
+// Dwolla limits on transfer reconciliation fields
+const (
+	maxCorrelationIDLength = 255
+	maxMetadataPairs       = 10
+	maxMetadataKeyLength   = 255
+	maxMetadataValueLength = 255
+)
+
+var (
+	// Transfers created through this service keyed by transfer ID
+	transferRecords = map[string]*transferRecord{}
+	transferMutex   sync.RWMutex
+)
+
+// saveTransferRecord stores a newly created transfer
+func saveTransferRecord(record *transferRecord) {
+	now := time.Now().Format(time.RFC3339)
+	record.CreatedAt = now
+	record.UpdatedAt = now
+	record.History = []transferStatusEvent{{
+		Status:        record.Status,
+		Source:        "api",
+		CorrelationID: record.CorrelationID,
+		Metadata:      record.Metadata,
+		Timestamp:     now,
+	}}
+
+	transferMutex.Lock()
+	transferRecords[record.ID] = record
+	transferMutex.Unlock()
+}
+
+// getTransferRecord returns a copy of the local record of a transfer
+func getTransferRecord(transferID string) (transferRecord, bool) {
+	transferMutex.RLock()
+	defer transferMutex.RUnlock()
+	record, ok := transferRecords[transferID]
+	if !ok {
+		return transferRecord{}, false
+	}
+	copied := *record
+	copied.History = append([]transferStatusEvent(nil), record.History...)
+	return copied, true
+}
+
+// setTransferStatus updates the local status of a known transfer and records the change.
+// source is "api" or the webhook topic that reported the status.
+// It reports whether the status changed and whether the transfer was created through this service.
+// Use updateTransferStatus, which also books the change in the ledger.
+func setTransferStatus(transferID, status, source, eventID string) (bool, bool) {
+	transferMutex.Lock()
+	defer transferMutex.Unlock()
+	record, ok := transferRecords[transferID]
+	if !ok {
+		return false, false
+	}
+	if record.Status == status {
+		return false, true
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	record.Status = status
+	record.UpdatedAt = now
+	record.History = append(record.History, transferStatusEvent{
+		Status:        status,
+		Source:        source,
+		EventID:       eventID,
+		CorrelationID: record.CorrelationID,
+		Metadata:      record.Metadata,
+		Timestamp:     now,
+	})
+	return true, true
+}
+
+// updateTransferStatus records a status change on the transfer and in the ledger. Every
+// status change goes through here so the two cannot drift apart. A transfer that has just
+// failed, whether reported by a webhook or seen in a lookup, has its return reason looked
+// up in the background and may be scheduled for a retry.
+func updateTransferStatus(transferID, status, source, eventID string) bool {
+	changed, ok := setTransferStatus(transferID, status, source, eventID)
+	// The transfers of mass payment items are only known to the ledger
+	applyLedgerStatus(transferID, status, source, eventID)
+	if !ok {
+		return false
+	}
+	if changed && status == transferStatusFailed {
+		go recordTransferFailure(transferID)
+	}
+	return true
+}
+
+// transferStatusFromTopic maps transfer webhook topics to transfer statuses.
+// Bank transfer legs (customer_bank_transfer_*) have their own IDs and are not mapped.
+func transferStatusFromTopic(topic string) string {
+	topic = strings.TrimPrefix(topic, "customer_")
+	switch topic {
+	case "transfer_completed":
+		return transferStatusProcessed
+	case "transfer_failed":
+		return transferStatusFailed
+	case "transfer_cancelled":
+		return transferStatusCancelled
+	}
+	return ""
+}
+
+// updateTransferFromWebhook updates local transfer state from a transfer webhook
+func updateTransferFromWebhook(topic string, webhook map[string]interface{}) {
+	status := transferStatusFromTopic(topic)
+	if status == "" {
+		return
+	}
+
+	links, _ := webhook["_links"].(map[string]interface{})
+	transferID := resourceIDFromHref(linkHref(links, "resource"))
+	eventID, _ := webhook["id"].(string)
+	if !updateTransferStatus(transferID, status, topic, eventID) {
+		return
+	}
+
+	record, _ := getTransferRecord(transferID)
+	if record.CorrelationID != "" {
+		fmt.Printf("Transfer %s (correlationId %s) is now %s\n", transferID, record.CorrelationID, status)
+	} else {
+		fmt.Printf("Transfer %s is now %s\n", transferID, status)
+	}
+}
+
+// validateTransferMetadata checks a correlationId and metadata map against Dwolla's limits
+func validateTransferMetadata(correlationID string, metadata map[string]string) error {
+	if len(correlationID) > maxCorrelationIDLength {
+		return fmt.Errorf("correlationId must be %d characters or fewer", maxCorrelationIDLength)
+	}
+	if len(metadata) > maxMetadataPairs {
+		return fmt.Errorf("metadata may have at most %d keys", maxMetadataPairs)
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata keys must be 1 to %d characters", maxMetadataKeyLength)
+		}
+		if len(value) > maxMetadataValueLength {
+			return fmt.Errorf("metadata value for %q must be %d characters or fewer", key, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// transferRequest describes a transfer to initiate
+type transferRequest struct {
+	Source        string               `json:"source" binding:"required"`
+	Destination   string               `json:"destination" binding:"required"`
+	Amount        float64              `json:"amount" binding:"required"`
+	Currency      string               `json:"currency"`
+	CorrelationID string               `json:"correlationId"`
+	Metadata      map[string]string    `json:"metadata"`
+	Fees          []transferFeeRequest `json:"fees"`
+	transferOptions
+}
+
+// initiateTransfer validates a transfer request, creates the transfer in Dwolla and records it locally.
+// A non-empty idempotencyKey makes repeated calls return the transfer created by the first one.
+func initiateTransfer(req transferRequest, idempotencyKey string) (transferRecord, int, error) {
+	if err := validateTransferMetadata(req.CorrelationID, req.Metadata); err != nil {
+		return transferRecord{}, http.StatusBadRequest, err
+	}
+
+	// The amount is rounded to cents once, so the checks below see exactly what Dwolla is sent
+	cents := amountToCents(req.Amount)
+	if cents <= 0 {
+		return transferRecord{}, http.StatusBadRequest, fmt.Errorf("amount must be at least 0.01")
+	}
+	amount := centsToAmount(cents)
+
+	// Source and destination may be funding source IDs or URLs
+	_, sourceURL, err := resolveResource(resourceFundingSources, req.Source)
+	if err != nil {
+		return transferRecord{}, http.StatusBadRequest, fmt.Errorf("invalid source: %w", err)
+	}
+	_, destinationURL, err := resolveResource(resourceFundingSources, req.Destination)
+	if err != nil {
+		return transferRecord{}, http.StatusBadRequest, fmt.Errorf("invalid destination: %w", err)
+	}
+
+	// Refuse funding sources whose customers are suspended or deactivated
+	source, status, err := checkFundingSourceActive(sourceURL)
+	if err != nil {
+		return transferRecord{}, status, err
+	}
+	destination, status, err := checkFundingSourceActive(destinationURL)
+	if err != nil {
+		return transferRecord{}, status, err
+	}
+
+	// Same-day ACH, addenda and real-time payments depend on the funding source types
+	if err := req.transferOptions.validate(source, destination); err != nil {
+		return transferRecord{}, http.StatusBadRequest, err
+	}
+
+	// Debits from a Dwolla balance are checked up front for a clear error
+	if source.Type == "balance" {
+		if status, err := checkBalanceAvailable(source, cents); err != nil {
+			return transferRecord{}, status, err
+		}
+	}
+
+	// Set default currency
+	currency := req.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+
+	fees, err := validateFees(req.Fees, cents, currency, source, destination)
+	if err != nil {
+		return transferRecord{}, http.StatusBadRequest, err
+	}
+
+	// Create transfer payload
+	payload := map[string]interface{}{
+		"_links": map[string]interface{}{
+			"source": map[string]string{
+				"href": sourceURL,
+			},
+			"destination": map[string]string{
+				"href": destinationURL,
+			},
+		},
+		"amount": map[string]interface{}{
+			"currency": currency,
+			"value":    amount,
+		},
+	}
+	if req.CorrelationID != "" {
+		payload["correlationId"] = req.CorrelationID
+	}
+	if len(req.Metadata) > 0 {
+		payload["metadata"] = req.Metadata
+	}
+	req.transferOptions.apply(payload)
+	applyFees(payload, fees)
+
+	url := DWOLLA_BASE_URL + "/transfers"
+	result, status, err := makeDwollaIdempotentRequest("POST", url, payload, idempotencyKey)
+	if err != nil {
+		return transferRecord{}, http.StatusInternalServerError, err
+	}
+
+	if status != http.StatusCreated {
+		return transferRecord{}, status, fmt.Errorf("failed to create transfer: %v", result)
+	}
+
+	transferURL := result["location"].(string)
+	transferID := resourceIDFromHref(transferURL)
+
+	// A replayed idempotency key returns the transfer that already exists
+	if existing, ok := getTransferRecord(transferID); ok {
+		fmt.Printf("Transfer already created for idempotency key %s: %s\n", idempotencyKey, transferURL)
+		return existing, http.StatusOK, nil
+	}
+
+	fmt.Printf("Created transfer: %s\n", transferURL)
+
+	record := &transferRecord{
+		ID:             transferID,
+		Href:           transferURL,
+		SourceURL:      sourceURL,
+		DestinationURL: destinationURL,
+		Amount:         amount,
+		Currency:       currency,
+		Status:         transferStatusPending,
+		CorrelationID:  req.CorrelationID,
+		Metadata:       req.Metadata,
+		Fees:           fees,
+		Instant:        source.Type == "balance" && destination.Type == "balance",
+	}
+	if req.transferOptions != (transferOptions{}) {
+		options := req.transferOptions
+		record.Options = &options
+	}
+	saveTransferRecord(record)
+
+	saved, _ := getTransferRecord(transferID)
+	recordLedgerPending(saved, source, destination)
+	if saved.Instant {
+		saved = settleInstantTransfer(saved)
+	}
+	return saved, http.StatusOK, nil
+}
+
+// createTransfer initiates a transfer. An optional Idempotency-Key header is passed through to Dwolla.
+// POST /api/dwolla/transfer
+func createTransfer(c *gin.Context) {
+	var reqBody transferRequest
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, status, err := initiateTransfer(reqBody, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer_id":     record.ID,
+		"transfer_url":    record.Href,
+		"status":          "created",
+		"instant":         record.Instant,
+		"transfer_status": record.Status,
+	})
+}
+
+// getTransfer retrieves transfer details
+// GET /api/dwolla/transfer/:id
+func getTransfer(c *gin.Context) {
+	transferID, url, err := resolveResource(resourceTransfers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("GET", url, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to get transfer", "details": result})
+		return
+	}
+
+	// Keep local state in sync with what Dwolla reports
+	if dwollaStatus, ok := result["status"].(string); ok {
+		updateTransferStatus(transferID, dwollaStatus, "api", "")
+	}
+
+	// Include facilitator fees when Dwolla reports any
+	links, _ := result["_links"].(map[string]interface{})
+	if linkHref(links, "fees") != "" {
+		fees, err := fetchTransferFees(url)
+		if err != nil {
+			fmt.Printf("⚠ Failed to get fees for transfer %s: %v\n", transferID, err)
+		} else {
+			result["fees"] = fees
+		}
+	}
+
+	// Include the return reason of failed transfers
+	if linkHref(links, "failure") != "" {
+		failure, _, err := fetchTransferFailure(url)
+		if err != nil {
+			fmt.Printf("⚠ Failed to get failure reason for transfer %s: %v\n", transferID, err)
+		} else {
+			setTransferFailure(transferID, failure)
+			result["failure"] = failure
+		}
+	}
+
+	if record, ok := getTransferRecord(transferID); ok {
+		result["local"] = record
+	}
+
+	// Show refunds made against this transfer
+	if refunds := refundsOf(transferID); len(refunds) > 0 {
+		if amount, ok := result["amount"].(map[string]interface{}); ok {
+			value, _ := amount["value"].(string)
+			result["refund_history"] = refundSummary(value, refunds)
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// cancelTransfer cancels a pending transfer
+// POST /api/dwolla/transfer/:id/cancel
+func cancelTransfer(c *gin.Context) {
+	transferID, transferURL, err := resolveResource(resourceTransfers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := makeDwollaRequest("GET", transferURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to get transfer", "details": result})
+		return
+	}
+
+	// Dwolla only includes a cancel link while the transfer can still be cancelled
+	currentStatus, _ := result["status"].(string)
+	links, _ := result["_links"].(map[string]interface{})
+	if linkHref(links, "cancel") == "" {
+		message := "transfer can no longer be cancelled"
+		switch currentStatus {
+		case transferStatusProcessed:
+			message = "transfer has already been processed and cannot be cancelled"
+		case transferStatusCancelled:
+			message = "transfer is already cancelled"
+		case transferStatusFailed:
+			message = "transfer has failed and cannot be cancelled"
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       message,
+			"transfer_id": transferID,
+			"status":      currentStatus,
+		})
+		return
+	}
+
+	payload := map[string]interface{}{
+		"status": transferStatusCancelled,
+	}
+
+	result, status, err = makeDwollaRequest("POST", transferURL, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to cancel transfer", "details": result})
+		return
+	}
+
+	newStatus, _ := result["status"].(string)
+	if newStatus == "" {
+		newStatus = transferStatusCancelled
+	}
+	updateTransferStatus(transferID, newStatus, "api", "")
+
+	fmt.Printf("⚠ Cancelled transfer: %s\n", transferURL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer_id":  transferID,
+		"transfer_url": transferURL,
+		"status":       newStatus,
+	})
+}
+
+// transferAmount is a Dwolla money amount
+type transferAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// transfer is the typed view of a Dwolla transfer resource
+type transfer struct {
+	ID             string                 `json:"id"`
+	Href           string                 `json:"href"`
+	Status         string                 `json:"status"`
+	Amount         transferAmount         `json:"amount"`
+	Created        string                 `json:"created"`
+	CorrelationID  string                 `json:"correlationId,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	SourceURL      string                 `json:"sourceUrl"`
+	DestinationURL string                 `json:"destinationUrl"`
+	Cancellable    bool                   `json:"cancellable"`
+}
+
+// transferFromResult converts a Dwolla transfer response into a transfer
+func transferFromResult(result map[string]interface{}) (transfer, error) {
+	var t transfer
+	if err := decodeDwollaResource(result, &t); err != nil {
+		return transfer{}, err
+	}
+
+	links, _ := result["_links"].(map[string]interface{})
+	t.Href = linkHref(links, "self")
+	if t.Href == "" && t.ID != "" {
+		t.Href = resourceHref(resourceTransfers, t.ID)
+	}
+	t.SourceURL = linkHref(links, "source")
+	t.DestinationURL = linkHref(links, "destination")
+	t.Cancellable = linkHref(links, "cancel") != ""
+	return t, nil
+}
+
+// encodeTransferCursor turns a Dwolla HAL next link into an opaque cursor
+func encodeTransferCursor(nextHref string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(nextHref))
+}
+
+// decodeTransferCursor turns a cursor back into a Dwolla URL, making sure it
+// still points at the given customer's transfers
+func decodeTransferCursor(cursor, customerURL string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor")
+	}
+
+	nextHref := string(data)
+	if !strings.HasPrefix(nextHref, customerURL+"/transfers?") {
+		return "", fmt.Errorf("cursor does not belong to this customer")
+	}
+	return nextHref, nil
+}
+
+// listCustomerTransfers lists and searches a customer's transfers.
+// Filters are passed through to Dwolla; pass next_cursor back as cursor to get the next page.
+// GET /api/dwolla/customer/:id/transfers?startDate=&endDate=&startAmount=&endAmount=&status=&correlationId=&search=&limit=&cursor=
+func listCustomerTransfers(c *gin.Context) {
+	customerID, customerURL, err := resolveResource(resourceCustomers, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestURL string
+	if cursor := c.Query("cursor"); cursor != "" {
+		requestURL, err = decodeTransferCursor(cursor, customerURL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		query, err := transferSearchQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		requestURL = customerURL + "/transfers?" + query.Encode()
+	}
+
+	result, status, err := makeDwollaRequest("GET", requestURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": "Failed to list transfers", "details": result})
+		return
+	}
+
+	transfers := []transfer{}
+	embedded, _ := result["_embedded"].(map[string]interface{})
+	rawTransfers, _ := embedded["transfers"].([]interface{})
+	for _, raw := range rawTransfers {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t, err := transferFromResult(item)
+		if err != nil {
+			continue
+		}
+		transfers = append(transfers, t)
+	}
+
+	total, _ := result["total"].(float64)
+	response := gin.H{
+		"customer_id": customerID,
+		"transfers":   transfers,
+		"total":       int(total),
+	}
+
+	links, _ := result["_links"].(map[string]interface{})
+	if next := linkHref(links, "next"); next != "" {
+		response["next_cursor"] = encodeTransferCursor(next)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// transferSearchQuery validates transfer search filters and converts them into Dwolla query parameters
+func transferSearchQuery(c *gin.Context) (url.Values, error) {
+	query := url.Values{}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+	if err != nil || limit < 1 || limit > 200 {
+		return nil, fmt.Errorf("limit must be between 1 and 200")
+	}
+	query.Set("limit", strconv.Itoa(limit))
+
+	for _, key := range []string{"startDate", "endDate"} {
+		if value := c.Query(key); value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("%s must be formatted as YYYY-MM-DD", key)
+			}
+			query.Set(key, value)
+		}
+	}
+
+	for _, key := range []string{"startAmount", "endAmount"} {
+		if value := c.Query(key); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative amount", key)
+			}
+			query.Set(key, fmt.Sprintf("%.2f", amount))
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case transferStatusPending, transferStatusProcessed, transferStatusFailed, transferStatusCancelled:
+			query.Set("status", status)
+		default:
+			return nil, fmt.Errorf("status must be 'pending', 'processed', 'failed' or 'cancelled'")
+		}
+	}
+
+	if correlationID := c.Query("correlationId"); correlationID != "" {
+		query.Set("correlationId", correlationID)
+	}
+	if search := c.Query("search"); search != "" {
+		query.Set("search", search)
+	}
+
+	return query, nil
+}
+
+// searchLocalTransfers searches transfers created through this service by
+// correlationId, status and metadata values (metadata.<key>=<value>)
+// GET /api/dwolla/transfers?correlationId=order-123&metadata.orderId=123
+func searchLocalTransfers(c *gin.Context) {
+	correlationID := c.Query("correlationId")
+	status := c.Query("status")
+
+	metadataFilters := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		if strings.HasPrefix(key, "metadata.") && len(values) > 0 {
+			metadataFilters[strings.TrimPrefix(key, "metadata.")] = values[0]
+		}
+	}
+
+	transferMutex.RLock()
+	records := []transferRecord{}
+	for _, record := range transferRecords {
+		if correlationID != "" && record.CorrelationID != correlationID {
+			continue
+		}
+		if status != "" && record.Status != status {
+			continue
+		}
+		matches := true
+		for key, value := range metadataFilters {
+			if record.Metadata[key] != value {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		copied := *record
+		copied.History = append([]transferStatusEvent(nil), record.History...)
+		records = append(records, copied)
+	}
+	transferMutex.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt > records[j].CreatedAt
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers": records,
+		"total":     len(records),
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestSetTransferStatus(t *testing.T) {
+	saveTransferRecord(&transferRecord{ID: "status-test", Status: transferStatusPending})
+	t.Cleanup(func() {
+		transferMutex.Lock()
+		delete(transferRecords, "status-test")
+		transferMutex.Unlock()
+	})
+
+	// Only the first report of a status is a change, so a failure starts its retry handling once
+	tests := []struct {
+		name        string
+		transferID  string
+		status      string
+		wantChanged bool
+		wantOK      bool
+	}{
+		{"unknown transfer", "other", transferStatusFailed, false, false},
+		{"same status", "status-test", transferStatusPending, false, true},
+		{"failed from a lookup", "status-test", transferStatusFailed, true, true},
+		{"failed again from the webhook", "status-test", transferStatusFailed, false, true},
+	}
+
+	for _, tt := range tests {
+		changed, ok := setTransferStatus(tt.transferID, tt.status, "test", "")
+		if changed != tt.wantChanged || ok != tt.wantOK {
+			t.Errorf("%s: changed %v, ok %v; want %v, %v", tt.name, changed, ok, tt.wantChanged, tt.wantOK)
+		}
+	}
+
+	record, _ := getTransferRecord("status-test")
+	if len(record.History) != 2 || record.Status != transferStatusFailed {
+		t.Errorf("status %s with %d history events, want failed with 2", record.Status, len(record.History))
+	}
+}
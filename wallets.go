@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkBalanceAvailable refuses to debit more than a balance funding source holds
+func checkBalanceAvailable(fs fundingSource, cents int64) (int, error) {
+	available, status, err := fetchFundingSourceBalance(fs)
+	if err != nil {
+		return status, err
+	}
+	availableCents, err := parseAmountCents(available.Balance.Value)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if cents > availableCents {
+		return http.StatusConflict, fmt.Errorf("amount of %s exceeds the available balance of %s", centsToAmount(cents), available.Balance.Value)
+	}
+	return http.StatusOK, nil
+}
+
+// settleInstantTransfer records the status of a balance-to-balance transfer, which
+// Dwolla processes as soon as it is created instead of over ACH
+func settleInstantTransfer(record transferRecord) transferRecord {
+	result, status, err := makeDwollaRequest("GET", record.Href, nil)
+	if err != nil || status != http.StatusOK {
+		return record
+	}
+	t, err := transferFromResult(result)
+	if err != nil || t.Status == "" {
+		return record
+	}
+	updateTransferStatus(record.ID, t.Status, "api", "")
+
+	updated, _ := getTransferRecord(record.ID)
+	return updated
+}
+
+// fetchCustomerFundingSources returns a customer with its funding sources
+func fetchCustomerFundingSources(ref string) (customer, []fundingSource, int, error) {
+	cust, status, err := fetchCustomer(ref)
+	if err != nil {
+		return customer{}, nil, status, err
+	}
+	fundingSources, status, err := fetchOwnerFundingSources(cust.Href)
+	if err != nil {
+		return customer{}, nil, status, err
+	}
+	return cust, fundingSources, http.StatusOK, nil
+}
+
+// getCustomerBalance finds a customer's balance funding source and reads its balance.
+// Only verified personal and business customers have a balance.
+// GET /api/dwolla/customer/:id/balance
+func getCustomerBalance(c *gin.Context) {
+	cust, fundingSources, status, err := fetchCustomerFundingSources(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	fs, ok := balanceFundingSource(fundingSources)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":  "customer has no Dwolla balance; only verified customers can hold one",
+			"type":   cust.Type,
+			"status": cust.Status,
+		})
+		return
+	}
+
+	balance, status, err := fetchFundingSourceBalance(fs)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":        cust.ID,
+		"customer_url":       cust.Href,
+		"funding_source_id":  fs.ID,
+		"funding_source_url": fs.Href,
+		"balance":            balance.Balance,
+		"total":              balance.Total,
+		"last_updated":       balance.LastUpdated,
+	})
+}
+
+// createCustomerBalanceTransfer moves funds between a customer's balance and one of their bank accounts.
+// An optional Idempotency-Key header is passed through to Dwolla.
+// POST /api/dwolla/customer/:id/balance/transfer
+func createCustomerBalanceTransfer(c *gin.Context) {
+	var reqBody balanceTransferRequest
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cust, fundingSources, status, err := fetchCustomerFundingSources(c.Param("id"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, status, err := balanceTransfer(fundingSources, reqBody, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("👛 Customer %s balance %s of %s %s: %s\n", cust.ID, reqBody.Direction, record.Amount, record.Currency, record.Href)
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":  cust.ID,
+		"transfer_id":  record.ID,
+		"transfer_url": record.Href,
+		"direction":    reqBody.Direction,
+		"source":       record.SourceURL,
+		"destination":  record.DestinationURL,
+		"amount":       record.Amount,
+		"status":       "created",
+	})
+}